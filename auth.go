@@ -9,4 +9,10 @@ type Authenticator interface {
 	GetAccessToken(ctx context.Context) (string, error)
 	Expiry() time.Time
 	Refresh(ctx context.Context) (string, error)
+
+	// Invalidate marks accessToken as rejected by the API, so the next call to
+	// GetAccessToken refreshes the session or logs in again. It is a no-op if
+	// accessToken has already been replaced, which lets concurrent requests
+	// failing with the same token trigger a single refresh.
+	Invalidate(accessToken string)
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
+	"net/mail"
 	"net/textproto"
 	"strings"
 	"time"
@@ -13,7 +14,12 @@ import (
 
 func (c *Client) GetBulkVirtualCardUpload(ctx context.Context, uploadId string) (*BulkVirtualCardUpload, error) {
 	var response bulkVirtualCardUploadResponse
-	err := c.jsonRequest(ctx, http.MethodGet, fmt.Sprintf("/bulkvirtualcarduploads/%s", uploadId), nil, &response)
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "GetBulkVirtualCardUpload",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/bulkvirtualcarduploads/%s", uploadId),
+		ResourceID: uploadId,
+	}, nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -47,16 +53,47 @@ type bulkVirtualCardUploadResponse struct {
 }
 
 func (c *Client) BulkCreateVirtualCards(ctx context.Context, cardId string, options []BulkCreateVirtualCard) (*BulkVirtualCardPushResponse, error) {
+	if cardId == "" {
+		return nil, validationError("credit card ID is required")
+	}
+	if len(options) == 0 {
+		return nil, validationError("no virtual cards to create")
+	}
+
+	// The start date column is only sent when used, so uploads of cards
+	// usable right away keep the original template
+	scheduled := false
+	for _, option := range options {
+		if !option.ValidFrom.IsZero() {
+			scheduled = true
+		}
+	}
+
 	var csv strings.Builder
 	csv.WriteString(`"Card Type","en-US","Virtual Card User Email","Card Name","Credit Limit","Active Until Date (MM/DD/YYYY)","Notes"`)
-	for _, option := range options {
+	if scheduled {
+		csv.WriteString(`,"Active From Date (MM/DD/YYYY)"`)
+	}
+	for i, option := range options {
+		err := option.validate()
+		if err != nil {
+			return nil, fmt.Errorf("card %d: %w", i+1, err)
+		}
+
 		csv.WriteString("\n")
 		csv.WriteString(
 			fmt.Sprintf(
 				`"%s","en-US","%s","%s",%.2f,"%s","%s"`,
-				option.CardType, option.Recipient, option.DisplayName, float64(option.BalanceCents)/100, option.ValidTo.Format("01/02/2006"), option.Notes,
+				csvEscape(string(option.CardType)), csvEscape(option.Recipient), csvEscape(option.DisplayName), float64(option.BalanceCents)/100, option.ValidTo.Format("01/02/2006"), csvEscape(option.Notes),
 			),
 		)
+		if scheduled {
+			validFrom := ""
+			if !option.ValidFrom.IsZero() {
+				validFrom = option.ValidFrom.Format("01/02/2006")
+			}
+			csv.WriteString(fmt.Sprintf(`,"%s"`, validFrom))
+		}
 	}
 
 	body := new(bytes.Buffer)
@@ -72,7 +109,36 @@ func (c *Client) BulkCreateVirtualCards(ctx context.Context, cardId string, opti
 	form.Close()
 
 	var response BulkVirtualCardPushResponse
-	err = c.request(ctx, http.MethodPost, fmt.Sprintf("/creditcards/%s/bulkvirtualcardpush", cardId), form.FormDataContentType(), body, &response)
+	err = c.request(ctx, &Request{
+		Operation:   "BulkCreateVirtualCards",
+		Method:      http.MethodPost,
+		Path:        fmt.Sprintf("/creditcards/%s/bulkvirtualcardpush", cardId),
+		ResourceID:  cardId,
+		ContentType: form.FormDataContentType(),
+		Body:        body.Bytes(),
+		synthetic: func(ctx context.Context) (any, error) {
+			push := BulkVirtualCardPush{BulkVirtualCardUploadID: dryRunID()}
+			for _, option := range options {
+				record := BulkVirtualCardRecord{
+					CreditCardID: cardId,
+					Recipient:    option.Recipient,
+					DisplayName:  option.DisplayName,
+					BalanceCents: option.BalanceCents,
+					ValidToDate:  dateParts(option.ValidTo),
+					IsPush:       true,
+				}
+				if !option.ValidFrom.IsZero() {
+					record.ValidFromDate = dateParts(option.ValidFrom)
+				}
+				push.Tasks = append(push.Tasks, BulkVirtualCardTask{
+					TaskID: dryRunID(),
+					Status: BulkVirtualCardUploadStatusInitiated,
+					Record: record,
+				})
+			}
+			return BulkVirtualCardPushResponse{BulkVirtualCardPush: push}, nil
+		},
+	}, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +154,49 @@ type BulkCreateVirtualCard struct {
 	DisplayName  string
 	BalanceCents int
 
+	// ValidFrom is the date the card becomes usable (date only) in the
+	// timezone of the recipient, the card is usable right away when zero
+	ValidFrom time.Time
+
 	// ValidTo is the date the card expires (date only)
 	ValidTo time.Time
 	Notes   string
 }
 
+func (o BulkCreateVirtualCard) validate() error {
+	switch {
+	case o.CardType == "":
+		return validationError("card type is required")
+	case o.Recipient == "":
+		return validationError("recipient is required")
+	case o.DisplayName == "":
+		return validationError("display name is required")
+	case o.BalanceCents <= 0:
+		return validationError("balance must be positive, got %d cents", o.BalanceCents)
+	case o.ValidTo.IsZero():
+		return validationError("valid to date is required")
+	case !o.ValidFrom.IsZero() && o.ValidFrom.Format(dateLayout) > o.ValidTo.Format(dateLayout):
+		return validationError("valid from date %s is after valid to date %s", o.ValidFrom.Format(dateLayout), o.ValidTo.Format(dateLayout))
+	}
+
+	if _, err := mail.ParseAddress(o.Recipient); err != nil {
+		return validationError("invalid recipient %q", o.Recipient)
+	}
+
+	return nil
+}
+
+// dateParts is the year, month and day of a date, as encoded in bulk
+// records
+func dateParts(date time.Time) []int {
+	return []int{date.Year(), int(date.Month()), date.Day()}
+}
+
+// csvEscape doubles quotes so values can't break out of their CSV field
+func csvEscape(value string) string {
+	return strings.ReplaceAll(value, `"`, `""`)
+}
+
 type BulkVirtualCardRecord struct {
 	CreditCardID   string `json:"creditCardId"`
 	Recipient      string `json:"recipient"`
@@ -101,6 +205,7 @@ type BulkVirtualCardRecord struct {
 	Direct         bool   `json:"direct"`
 	BalanceCents   int    `json:"balanceCents"`
 	Currency       string `json:"currency"`
+	ValidFromDate  []int  `json:"validFromDate,omitempty"`
 	ValidToDate    []int  `json:"validToDate"`
 	Recurs         bool   `json:"recurs"`
 	HasPlasticCard bool   `json:"hasPlasticCard"`
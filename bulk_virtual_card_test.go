@@ -0,0 +1,153 @@
+package extend_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"mime"
+	"mime/multipart"
+	"slices"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestBulkCreateVirtualCards(t *testing.T) {
+	validFrom := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)
+	validTo := time.Date(2027, 3, 31, 0, 0, 0, 0, time.UTC)
+	card := func(recipient, name string, from time.Time) extend.BulkCreateVirtualCard {
+		return extend.BulkCreateVirtualCard{
+			CardType:     extend.VirtualCardTypeStandard,
+			Recipient:    recipient,
+			DisplayName:  name,
+			BalanceCents: 12345,
+			ValidFrom:    from,
+			ValidTo:      validTo,
+		}
+	}
+	header := []string{"Card Type", "en-US", "Virtual Card User Email", "Card Name", "Credit Limit", "Active Until Date (MM/DD/YYYY)", "Notes"}
+	scheduledHeader := append(slices.Clone(header), "Active From Date (MM/DD/YYYY)")
+
+	tests := []struct {
+		name    string
+		options []extend.BulkCreateVirtualCard
+		rejects string
+		// rows is the uploaded CSV, header included
+		rows          [][]string
+		wantErr       error
+		invalidEmails []string
+		// validFrom are the start dates of the cards created, nil for cards
+		// usable right away
+		validFrom []*time.Time
+	}{
+		{
+			name:    "usable right away",
+			options: []extend.BulkCreateVirtualCard{card("alice@example.com", "Alice", time.Time{})},
+			rows: [][]string{
+				header,
+				{"STANDARD", "en-US", "alice@example.com", "Alice", "123.45", "03/31/2027", ""},
+			},
+			validFrom: []*time.Time{nil},
+		},
+		{
+			name:    "scheduled",
+			options: []extend.BulkCreateVirtualCard{card("alice@example.com", "Alice", validFrom), card("bob@example.com", "Bob", time.Time{})},
+			rows: [][]string{
+				scheduledHeader,
+				{"STANDARD", "en-US", "alice@example.com", "Alice", "123.45", "03/31/2027", "", "03/01/2027"},
+				{"STANDARD", "en-US", "bob@example.com", "Bob", "123.45", "03/31/2027", "", ""},
+			},
+			validFrom: []*time.Time{&validFrom, nil},
+		},
+		{
+			name:    "quotes and commas",
+			options: []extend.BulkCreateVirtualCard{card("alice@example.com", `Alice "Al", Jr`, time.Time{})},
+			rows: [][]string{
+				header,
+				{"STANDARD", "en-US", "alice@example.com", `Alice "Al", Jr`, "123.45", "03/31/2027", ""},
+			},
+			validFrom: []*time.Time{nil},
+		},
+		{
+			name:          "rejected recipient",
+			options:       []extend.BulkCreateVirtualCard{card("alice@example.com", "Alice", time.Time{}), card("bounce@example.com", "Bounce", time.Time{})},
+			rejects:       "bounce@example.com",
+			invalidEmails: []string{"bounce@example.com"},
+			validFrom:     []*time.Time{nil},
+		},
+		{
+			name:    "starts after it expires",
+			options: []extend.BulkCreateVirtualCard{card("alice@example.com", "Alice", validTo.AddDate(0, 0, 1))},
+			wantErr: extend.ErrValidation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			if test.rejects != "" {
+				server.RejectRecipient(test.rejects, "mailbox unavailable")
+			}
+
+			push, err := server.Client().BulkCreateVirtualCards(context.Background(), "cc_1", test.options)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				if n := len(server.Requests()); n != 0 {
+					t.Errorf("sent %d requests", n)
+				}
+				return
+			}
+
+			if test.rows != nil {
+				requests := server.Requests()
+				if len(requests) != 1 {
+					t.Fatalf("sent %d requests, want 1", len(requests))
+				}
+				rows := readUploadedCSV(t, requests[0].Header.Get("Content-Type"), requests[0].Body)
+				if !slices.EqualFunc(rows, test.rows, slices.Equal) {
+					t.Errorf("uploaded %q\nwant %q", rows, test.rows)
+				}
+			}
+
+			if !slices.Equal(push.InvalidEmails, test.invalidEmails) && len(push.InvalidEmails)+len(test.invalidEmails) > 0 {
+				t.Errorf("got invalid emails %q, want %q", push.InvalidEmails, test.invalidEmails)
+			}
+			cards := server.Cards()
+			if len(cards) != len(test.validFrom) {
+				t.Fatalf("server holds %d cards, want %d", len(cards), len(test.validFrom))
+			}
+			for i, card := range cards {
+				want := test.validFrom[i]
+				switch {
+				case want == nil && card.ValidFrom != nil && card.ValidFrom.After(time.Now()):
+					t.Errorf("card %d starts on %s, want right away", i, card.ValidFrom)
+				case want != nil && (card.ValidFrom == nil || !card.ValidFrom.Equal(*want)):
+					t.Errorf("card %d starts on %v, want %s", i, card.ValidFrom, want)
+				}
+			}
+		})
+	}
+}
+
+func readUploadedCSV(t *testing.T, contentType string, body []byte) [][]string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(part).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
@@ -0,0 +1,256 @@
+// Package cassette records HTTP interactions with the Extend API and
+// Cognito to fixture files and replays them, so tests can exercise real
+// responses without credentials.
+//
+//	rec, err := cassette.New("testdata/list_cards.json", cassette.ModeAuto)
+//	defer rec.Save()
+//
+//	auth.SetHTTPClient(rec.Client())
+//	client.SetHTTPClient(rec.Client())
+//
+// Secrets are scrubbed before anything is written: tokens, card numbers,
+// security codes, emails and SRP values.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// ModeReplay serves recorded interactions and fails requests that
+	// weren't recorded
+	ModeReplay Mode = iota
+
+	// ModeRecord sends requests to the real API and records them,
+	// replacing the cassette
+	ModeRecord
+
+	// ModeAuto replays the cassette if it exists and records it otherwise
+	ModeAuto
+)
+
+var ErrNotRecorded = errors.New("cassette: interaction not recorded")
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions
+type Recorder struct {
+	// Transport sends requests in record mode, http.DefaultTransport when nil
+	Transport http.RoundTripper
+
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New opens the cassette at path. In replay mode the cassette must exist.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		path: path,
+		mode: mode,
+	}
+
+	if mode == ModeAuto {
+		r.mode = ModeReplay
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			r.mode = ModeRecord
+		}
+	}
+
+	if r.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		var file cassetteFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("cassette: decode %s: %w", path, err)
+		}
+		r.interactions = file.Interactions
+		r.used = make([]bool, len(file.Interactions))
+	}
+
+	return r, nil
+}
+
+// Recording reports whether requests are sent to the real API
+func (r *Recorder) Recording() bool {
+	return r.mode == ModeRecord
+}
+
+// Client returns an HTTP client using the recorder as transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL.String()),
+			Header: redactHeader(req.Header),
+			Body:   redactBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     redactHeader(res.Header),
+			Body:       redactBody(resBody),
+		},
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+
+	// Save as we go so a failing test still leaves a usable cassette
+	if err := r.Save(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// replay serves the first unused interaction with the same method, URL and
+// Cognito target. Bodies aren't compared since SRP values are random.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	url := redactURL(req.URL.String())
+	target := req.Header.Get("X-Amz-Target")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] ||
+			interaction.Request.Method != req.Method ||
+			interaction.Request.URL != url ||
+			interaction.Request.Header.Get("X-Amz-Target") != target {
+			continue
+		}
+		r.used[i] = true
+
+		body := interaction.Response.Body
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, url)
+}
+
+// Unused returns the recorded interactions that weren't replayed, useful
+// to assert a test made every expected call
+func (r *Recorder) Unused() []Interaction {
+	if r.mode == ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette. It does nothing in
+// replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(cassetteFile{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	return nil
+}
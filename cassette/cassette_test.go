@@ -0,0 +1,85 @@
+package cassette_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/cassette"
+	"local/extend/extendtest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cards.json")
+	ctx := context.Background()
+
+	server := extendtest.NewServer()
+	seeded := server.SeedCard(extend.VirtualCard{
+		DisplayName:  "Travel",
+		CreditCardID: "cc_1",
+		BalanceCents: 1000,
+		ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+	})
+	number, _, _ := server.CardNumber(seeded.ID)
+
+	rec, err := cassette.New(path, cassette.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Recording() {
+		t.Fatal("a missing cassette isn't recorded")
+	}
+	rec.Transport = server.Server.Client().Transport
+	client := extend.New(extendtest.Token(extendtest.DefaultToken), extend.WithBaseURL(server.URL), extend.WithHTTPClient(rec.Client()))
+	if _, err := client.RevealVirtualCard(ctx, seeded.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{number, extendtest.DefaultToken} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	rec, err = cassette.New(path, cassette.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Recording() {
+		t.Fatal("an existing cassette is recorded again")
+	}
+	client = extend.New(extendtest.Token(extendtest.DefaultToken), extend.WithBaseURL(server.URL), extend.WithHTTPClient(rec.Client()))
+	revealed, err := client.RevealVirtualCard(ctx, seeded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revealed.VirtualCardID != seeded.ID {
+		t.Errorf("replayed card %s, want %s", revealed.VirtualCardID, seeded.ID)
+	}
+	if unused := rec.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions weren't replayed", len(unused))
+	}
+	if _, err := client.GetVirtualCard(ctx, "vc_other"); !errors.Is(err, cassette.ErrNotRecorded) {
+		t.Errorf("got error %v, want %v", err, cassette.ErrNotRecorded)
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	_, err := cassette.New(filepath.Join(t.TempDir(), "missing.json"), cassette.ModeReplay)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,128 @@
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// redactedHeaders are removed from recorded requests and responses
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+// redactedFields are JSON fields replaced wherever they appear. SRP values
+// keep a valid hex or base64 placeholder so a recorded login can still be
+// replayed by the cognito client.
+var redactedFields = map[string]string{
+	"vcn":                         redacted,
+	"securitycode":                redacted,
+	"accesstoken":                 redacted,
+	"refreshtoken":                redacted,
+	"idtoken":                     redacted,
+	"refresh_token":               redacted,
+	"session":                     redacted,
+	"password":                    redacted,
+	"srp_a":                       "00",
+	"srp_b":                       "00",
+	"salt":                        "00",
+	"secret_block":                base64.StdEncoding.EncodeToString([]byte(redacted)),
+	"password_claim_secret_block": base64.StdEncoding.EncodeToString([]byte(redacted)),
+	"password_claim_signature":    base64.StdEncoding.EncodeToString([]byte(redacted)),
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redactEmail replaces an email with a stable fake one, so the same address
+// maps to the same placeholder in requests and responses
+func redactEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "user-" + hex.EncodeToString(sum[:4]) + "@example.com"
+}
+
+func redactString(value string) string {
+	return emailPattern.ReplaceAllStringFunc(value, redactEmail)
+}
+
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	header.Del("Content-Length")
+	for name, values := range header {
+		for i, value := range values {
+			values[i] = redactString(value)
+		}
+		header[name] = values
+	}
+	return header
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactString(rawURL)
+	}
+	query := u.Query()
+	for key, values := range query {
+		for i, value := range values {
+			values[i] = redactString(value)
+		}
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	u.Path = redactString(u.Path)
+	return u.String()
+}
+
+// redactBody scrubs JSON bodies field by field, other bodies such as the
+// bulk upload CSV only have their emails replaced
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return redactString(string(body))
+	}
+
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return redactString(string(body))
+	}
+	return string(data)
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if placeholder, ok := redactedFields[strings.ToLower(key)]; ok && field != nil {
+				v[key] = placeholder
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	case string:
+		return redactString(v)
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package cassette
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	alice := redactEmail("alice@example.org")
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", ""},
+		{"card", `{"virtualCard":{"id":"vc_1","vcn":"4111111111111111","securityCode":"123","last4":"1111"}}`,
+			`{"virtualCard":{"id":"vc_1","last4":"1111","securityCode":"[REDACTED]","vcn":"[REDACTED]"}}`},
+		{"tokens", `{"AuthenticationResult":{"AccessToken":"a","RefreshToken":"r","IdToken":"i","ExpiresIn":3600}}`,
+			`{"AuthenticationResult":{"AccessToken":"[REDACTED]","ExpiresIn":3600,"IdToken":"[REDACTED]","RefreshToken":"[REDACTED]"}}`},
+		{"SRP", `{"ChallengeParameters":{"SRP_B":"abcdef","SALT":"12","SECRET_BLOCK":"c2VjcmV0"},"Session":"s"}`,
+			`{"ChallengeParameters":{"SALT":"00","SECRET_BLOCK":"W1JFREFDVEVEXQ==","SRP_B":"00"},"Session":"[REDACTED]"}`},
+		{"emails in arrays", `{"users":[{"email":"Alice@example.org"},{"email":"alice@example.org"}]}`,
+			`{"users":[{"email":"` + alice + `"},{"email":"` + alice + `"}]}`},
+		{"null secret", `{"vcn":null}`, `{"vcn":null}`},
+		{"large numbers", `{"balanceCents":12345678901234567890}`, `{"balanceCents":12345678901234567890}`},
+		{"CSV", "recipient,balance\nalice@example.org,100\n", "recipient,balance\n" + alice + ",100\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := redactBody([]byte(test.body)); got != test.want {
+				t.Errorf("got %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	alice := redactEmail("alice@example.org")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.paywithextend.com/virtualcards/vc_1", "https://api.paywithextend.com/virtualcards/vc_1"},
+		{"https://api.paywithextend.com/recipients/validate?email=alice%40example.org", "https://api.paywithextend.com/recipients/validate?email=" + strings.ReplaceAll(alice, "@", "%40")},
+		{"https://api.paywithextend.com/users/alice@example.org", "https://api.paywithextend.com/users/" + alice},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			if got := redactURL(test.url); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{
+		"Authorization":  {"Bearer secret"},
+		"Set-Cookie":     {"session=secret"},
+		"Content-Length": {"42"},
+		"X-Recipient":    {"alice@example.org"},
+		"Content-Type":   {"application/json"},
+	}
+
+	got := redactHeader(header)
+
+	want := http.Header{
+		"Authorization": {redacted},
+		"Set-Cookie":    {redacted},
+		"X-Recipient":   {redactEmail("alice@example.org")},
+		"Content-Type":  {"application/json"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for name := range want {
+		if got.Get(name) != want.Get(name) {
+			t.Errorf("got %s %q, want %q", name, got.Get(name), want.Get(name))
+		}
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Error("the original header was changed")
+	}
+}
@@ -6,7 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ExtendPlatformBrand struct {
@@ -41,85 +46,258 @@ var (
 	}
 )
 
+// clone copies the brand so options can modify its headers without
+// affecting other clients
+func (b ExtendPlatformBrand) clone() ExtendPlatformBrand {
+	header := b.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return ExtendPlatformBrand{
+		APIBaseURL: b.APIBaseURL,
+		Header:     header,
+	}
+}
+
 type Client struct {
-	auth  Authenticator
-	brand ExtendPlatformBrand
-	http  *http.Client
+	auth       Authenticator
+	brand      ExtendPlatformBrand
+	apiVersion string
+	http       *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+	middleware []Middleware
+	logger     *slog.Logger
+
+	// limiter is built from rateLimit and routeLimits, sharedLimiter is set
+	// by WithRateLimiter and may be used by other clients
+	limiter          *RateLimiter
+	rateLimit        *RateLimit
+	routeLimits      map[string]RateLimit
+	sharedLimiter    *RateLimiter
+	sharedLimiterSet bool
+
+	dryRun       bool
+	dryRunReport func(DryRun)
+
+	journal   IdempotencyJournal
+	createsMu sync.Mutex
+	creates   map[string]*inflightCreate
+
+	revealDisabled bool
+	revealAudit    func(context.Context, RevealEvent)
+
+	tracerProvider trace.TracerProvider
 }
 
-func New(auth Authenticator) *Client {
-	return NewWithBrand(brandExtend, auth)
+func New(auth Authenticator, options ...Option) *Client {
+	return NewWithBrand(brandExtend, auth, options...)
 }
 
-func NewWithBrand(brand ExtendPlatformBrand, auth Authenticator) *Client {
-	return &Client{
-		auth:  auth,
-		brand: brand,
-		http:  http.DefaultClient,
+func NewWithBrand(brand ExtendPlatformBrand, auth Authenticator, options ...Option) *Client {
+	c := &Client{
+		auth:       auth,
+		brand:      brand.clone(),
+		apiVersion: DefaultAPIVersion,
+		http:       http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+		journal:    NewMemoryJournal(),
 	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	c.limiter = c.newLimiter()
+
+	if c.timeout > 0 {
+		client := *c.http
+		client.Timeout = c.timeout
+		c.http = &client
+	}
+
+	return c
 }
 
 func (c *Client) SetHTTPClient(client *http.Client) {
 	c.http = client
 }
 
-func (c *Client) request(ctx context.Context, method string, path string, contentType string, body io.Reader, response any) error {
-	req, err := http.NewRequestWithContext(ctx, method, c.brand.APIBaseURL+path, body)
-	if err != nil {
-		return err
-	}
+// SetRetryPolicy replaces the policy used to retry failed requests.
+// Use NoRetries to send every request exactly once.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// request runs req through the middleware chain and decodes the response
+// body into response
+func (c *Client) request(ctx context.Context, req *Request, response any) error {
+	req.Result = response
+
+	ctx, span := c.startSpan(ctx, req)
 
-	accessToken, err := c.auth.GetAccessToken(ctx)
+	start := time.Now()
+	res, err := c.chain()(ctx, req)
+	c.logRequest(ctx, req, res, err, time.Since(start))
+
+	endSpan(span, res, err)
+
+	return err
+}
+
+// handle is the innermost handler of the middleware chain
+func (c *Client) handle(ctx context.Context, req *Request) (*Response, error) {
+	var res *Response
+	var err error
+	if c.dryRun && req.Method != http.MethodGet {
+		res, err = c.simulate(ctx, req)
+	} else {
+		res, err = c.roundTrip(ctx, req)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header = c.brand.Header.Clone()
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Accept", "application/vnd.paywithextend.v2021-03-12+json")
+	if raw, ok := req.Result.(*rawBody); ok {
+		raw.data = res.Body
+		raw.contentType = res.Header.Get("Content-Type")
+		return res, nil
+	}
 
-	res, err := c.http.Do(req)
+	if req.Result != nil && len(res.Body) > 0 {
+		err = json.Unmarshal(res.Body, req.Result)
+	}
+	// Card numbers only reach the result of RevealVirtualCard, never the
+	// body seen by middleware
+	res.Body = scrubCardSecrets(res.Body)
 	if err != nil {
-		return err
+		return res, fmt.Errorf("decode response: %w", err)
 	}
 
-	defer res.Body.Close()
+	return res, nil
+}
 
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		body, err := io.ReadAll(res.Body)
+// roundTrip sends a request, retrying it according to the retry policy and
+// replaying it once with a fresh session when it is rejected with a 401
+func (c *Client) roundTrip(ctx context.Context, req *Request) (*Response, error) {
+	reauthenticated := false
+	for attempt := 1; ; attempt++ {
+		err := c.waitRateLimit(ctx, req.Path)
 		if err != nil {
-			return fmt.Errorf("bad status: %s", res.Status)
+			return nil, err
 		}
 
-		var errorResponse apiErrorResponse
-		err = json.Unmarshal(body, &errorResponse)
+		accessToken, err := c.auth.GetAccessToken(ctx)
 		if err != nil {
-			return fmt.Errorf("bad status: %s: %s", res.Status, string(body))
+			return nil, err
 		}
 
-		return errorResponse
-	}
-
-	if response != nil {
-		err = json.NewDecoder(res.Body).Decode(response)
+		res, err := c.send(ctx, req, accessToken)
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
 		if err != nil {
-			return fmt.Errorf("decode response: %w", err)
+			if attempt >= c.retry.MaxAttempts || !shouldRetryError(ctx, req, err) {
+				return nil, err
+			}
+			delay := c.retry.backoff(attempt, 0)
+			c.log(ctx, slog.LevelWarn, "retrying extend request",
+				slog.String("operation", req.Operation),
+				slog.Int("attempt", attempt),
+				slog.Duration("delay", delay),
+				slog.String("error", err.Error()),
+			)
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
 		}
-	}
 
-	return nil
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			// The token may have been revoked or the clock skewed, replay
+			// once with a fresh session before giving up
+			if res.StatusCode == http.StatusUnauthorized && !reauthenticated {
+				reauthenticated = true
+				c.log(ctx, slog.LevelWarn, "access token rejected, re-authenticating",
+					slog.String("operation", req.Operation),
+				)
+				c.auth.Invalidate(accessToken)
+				attempt--
+				continue
+			}
+
+			retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+			if res.StatusCode == http.StatusTooManyRequests {
+				c.pauseRateLimit(req.Path, retryAfter)
+			}
+
+			if attempt < c.retry.MaxAttempts && shouldRetryStatus(req, res.StatusCode) && !c.retry.waitsTooLong(retryAfter) {
+				delay := c.retry.backoff(attempt, retryAfter)
+				c.log(ctx, slog.LevelWarn, "retrying extend request",
+					slog.String("operation", req.Operation),
+					slog.Int("attempt", attempt),
+					slog.Duration("delay", delay),
+					slog.Int("status", res.StatusCode),
+				)
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			return nil, newAPIError(res, body)
+		}
+
+		return &Response{
+			StatusCode: res.StatusCode,
+			Header:     res.Header,
+			Body:       body,
+			Attempts:   attempt,
+		}, nil
+	}
 }
 
-func (c *Client) jsonRequest(ctx context.Context, method string, path string, body any, response any) error {
+// send performs a single attempt of a request. The body is rebuilt from the
+// request bytes so every attempt sends the full payload.
+func (c *Client) send(ctx context.Context, r *Request, accessToken string) (*http.Response, error) {
 	var bodyReader io.Reader
+	if r.Body != nil {
+		bodyReader = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, c.brand.APIBaseURL+r.Path, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = c.brand.Header.Clone()
+	for key, values := range r.Header {
+		setHeader(req.Header, key, values...)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", r.ContentType)
+	req.Header.Set("Accept", "application/vnd.paywithextend.v"+c.apiVersion+"+json")
+
+	return c.http.Do(req)
+}
+
+// rawBody receives the undecoded body of responses that aren't JSON
+type rawBody struct {
+	data        []byte
+	contentType string
+}
+
+func (c *Client) jsonRequest(ctx context.Context, req *Request, body any, response any) error {
 	if body != nil {
 		bodyBytes, err := json.Marshal(body)
 		if err != nil {
 			return err
 		}
-		bodyReader = bytes.NewBuffer(bodyBytes)
+		req.Body = bodyBytes
 	}
 
-	return c.request(ctx, method, path, "application/json", bodyReader, response)
+	req.ContentType = "application/json"
+	return c.request(ctx, req, response)
 }
@@ -0,0 +1,207 @@
+package extend_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/cognito"
+	"local/extend/cognito/cognitotest"
+	"local/extend/extendtest"
+)
+
+func TestRetries(t *testing.T) {
+	policy := extend.RetryPolicy{MaxAttempts: 3, MaxBackoff: 30 * time.Second}
+
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context, client *extend.Client) error
+		fault    extendtest.Fault
+		attempts int
+		// status is the status of the error returned, 0 for no error
+		status int
+	}{
+		{
+			name:     "GET gateway error",
+			call:     listCards,
+			fault:    extendtest.Fault{StatusCode: http.StatusBadGateway, Times: 1},
+			attempts: 2,
+		},
+		{
+			name:     "GET gateway errors exhaust attempts",
+			call:     listCards,
+			fault:    extendtest.Fault{StatusCode: http.StatusServiceUnavailable},
+			attempts: 3,
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			name:     "POST rate limited",
+			call:     inviteRecipient,
+			fault:    extendtest.Fault{Method: http.MethodPost, StatusCode: http.StatusTooManyRequests, Times: 1},
+			attempts: 2,
+		},
+		{
+			name:     "POST gateway error",
+			call:     inviteRecipient,
+			fault:    extendtest.Fault{Method: http.MethodPost, StatusCode: http.StatusBadGateway, Times: 1},
+			attempts: 1,
+			status:   http.StatusBadGateway,
+		},
+		{
+			name:     "server error",
+			call:     listCards,
+			fault:    extendtest.Fault{StatusCode: http.StatusInternalServerError, Times: 1},
+			attempts: 1,
+			status:   http.StatusInternalServerError,
+		},
+		{
+			name:     "Retry-After longer than MaxBackoff",
+			call:     listCards,
+			fault:    extendtest.Fault{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"120"}}, Times: 1},
+			attempts: 1,
+			status:   http.StatusTooManyRequests,
+		},
+		{
+			name:     "Retry-After within MaxBackoff",
+			call:     listCards,
+			fault:    extendtest.Fault{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}, Times: 1},
+			attempts: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			server.InjectFault(test.fault)
+			client := server.Client(extend.WithRetryPolicy(policy))
+
+			err := test.call(context.Background(), client)
+
+			var apiErr *extend.APIError
+			switch {
+			case test.status == 0 && err != nil:
+				t.Errorf("got error %v", err)
+			case test.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != test.status):
+				t.Errorf("got error %v, want status %d", err, test.status)
+			}
+			if got := len(server.Requests()); got != test.attempts {
+				t.Errorf("got %d attempts, want %d", got, test.attempts)
+			}
+		})
+	}
+}
+
+func listCards(ctx context.Context, client *extend.Client) error {
+	cards := client.ListVirtualCards(&extend.ListVirtualCardsOptions{})
+	cards.Next()
+	_, err := cards.Get(ctx)
+	return err
+}
+
+func inviteRecipient(ctx context.Context, client *extend.Client) error {
+	_, err := client.InviteRecipient(ctx, extend.InviteRecipientOptions{
+		Email:     "new@example.com",
+		FirstName: "New",
+		LastName:  "Recipient",
+	})
+	return err
+}
+
+func TestReauthentication(t *testing.T) {
+	params := cognito.AuthParams{
+		Username:       "bot@example.com",
+		Password:       "hunter2",
+		DeviceKey:      "us-east-1_device",
+		DevicePassword: "device-password",
+		DeviceGroupKey: "-group",
+	}
+
+	tests := []struct {
+		name string
+		// setup runs after the authenticator got its first access token
+		setup     func(server *extendtest.Server, idp *cognitotest.Server)
+		call      func(ctx context.Context, client *extend.Client) error
+		wantErr   error
+		requests  int
+		refreshes int
+	}{
+		{
+			name:     "valid token",
+			setup:    func(*extendtest.Server, *cognitotest.Server) {},
+			call:     listCards,
+			requests: 1,
+		},
+		{
+			name: "revoked token",
+			setup: func(_ *extendtest.Server, idp *cognitotest.Server) {
+				idp.RevokeAccessTokens()
+			},
+			call:      listCards,
+			requests:  2,
+			refreshes: 1,
+		},
+		{
+			name: "revoked token on POST",
+			setup: func(_ *extendtest.Server, idp *cognitotest.Server) {
+				idp.RevokeAccessTokens()
+			},
+			call:      inviteRecipient,
+			requests:  2,
+			refreshes: 1,
+		},
+		{
+			name: "rejected again",
+			setup: func(server *extendtest.Server, _ *cognitotest.Server) {
+				server.SetTokenValidator(func(string) bool { return false })
+			},
+			call:      listCards,
+			wantErr:   extend.ErrUnauthorized,
+			requests:  2,
+			refreshes: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp := cognitotest.NewServer()
+			defer idp.Close()
+			idp.AddUser(params)
+			auth := idp.NewCognito(params)
+
+			server := extendtest.NewServer()
+			defer server.Close()
+			server.SetTokenValidator(idp.ValidAccessToken)
+			client := extend.New(auth,
+				extend.WithBaseURL(server.URL),
+				extend.WithHTTPClient(server.Server.Client()),
+				extend.WithRetryPolicy(extend.RetryPolicy{MaxAttempts: 3}),
+				extend.WithRateLimiter(nil),
+			)
+
+			ctx := context.Background()
+			if _, err := auth.GetAccessToken(ctx); err != nil {
+				t.Fatal(err)
+			}
+			test.setup(server, idp)
+
+			err := test.call(ctx, client)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			requests := server.Requests()
+			if len(requests) != test.requests {
+				t.Fatalf("got %d requests, want %d", len(requests), test.requests)
+			}
+			if len(requests) == 2 && requests[0].Header.Get("Authorization") == requests[1].Header.Get("Authorization") {
+				t.Error("request replayed with the rejected token")
+			}
+			if got := idp.Calls("REFRESH_TOKEN_AUTH"); got != test.refreshes {
+				t.Errorf("got %d refreshes, want %d", got, test.refreshes)
+			}
+		})
+	}
+}
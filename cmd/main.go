@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -13,6 +16,7 @@ import (
 
 	"local/extend"
 	"local/extend/cognito"
+	"local/extend/metrics"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
@@ -20,6 +24,15 @@ import (
 
 var userStates = make(map[string]string)
 var cardDetails = make(map[string]map[string]string)
+var botMetrics = metrics.New()
+
+// createJournal is shared by the clients of all commands, so a command
+// repeated after a failed creation finds the card it may have issued
+var createJournal = extend.NewMemoryJournal()
+
+// account is the Extend user the bot is logged in as, only fetched at
+// startup when needsAccount
+var account *extend.User
 
 func main() {
 	err := godotenv.Load()
@@ -37,6 +50,25 @@ func main() {
 		log.Fatalf("Error creating Discord session: %v", err)
 	}
 
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", botMetrics)
+			log.Printf("Serving metrics on %s/metrics", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.Printf("Error serving metrics: %v", err)
+			}
+		}()
+	}
+
+	if needsAccount() {
+		account, err = checkAccount(context.Background())
+		if err != nil {
+			log.Fatalf("Error checking Extend account: %v", err)
+		}
+		log.Printf("Logged in to Extend as %s (%s) in organization %s", account.Email, account.OrganizationRole, account.OrganizationID)
+	}
+
 	dg.AddHandler(messageCreate)
 
 	err = dg.Open()
@@ -107,25 +139,19 @@ func handleCardCreation(s *discordgo.Session, m *discordgo.MessageCreate, userID
 		return fmt.Errorf("invalid balance amount: %v", err)
 	}
 
-	card, err := createVirtualCard(displayName, strconv.Itoa(balanceCents))
+	card, revealed, err := createVirtualCard(displayName, strconv.Itoa(balanceCents))
 	if err != nil {
 		return fmt.Errorf("failed to create virtual card: %v", err)
 	}
 
-	log.Printf("Card API Response: %+v\n", card)
-
-	if card == nil || card.Vcn == nil || card.SecurityCode == nil {
-		return fmt.Errorf("card details are incomplete")
-	}
+	slog.Info("virtual card created", "card", card)
 
-	vcn := *card.Vcn
-	securityCode := *card.SecurityCode
-	expiryDate := card.Expires.Format("01/2006")
+	vcn := revealed.Number
+	securityCode := revealed.SecurityCode
+	expiryDate := revealed.Expires.Format("01/2006")
 	cardLimit := card.LimitCents
 	cardVCID := card.ID
 
-	log.Printf("Virtual Card Details - VCN: %s, Security Code: %s, Expiry Date: %s\n", vcn, securityCode, expiryDate)
-
 	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("**Virtual Card Created**\nName: %s", card.DisplayName))
 	s.ChannelMessageSend(m.ChannelID, "Card Number:")
 	s.ChannelMessageSend(m.ChannelID, vcn)
@@ -146,54 +172,84 @@ func convertDollarsToCents(dollars string) (int, error) {
 	return int(amount * 100), nil
 }
 
-func createVirtualCard(displayName, balanceCents string) (*extend.VirtualCard, error) {
-	username := os.Getenv("COGNITO_USERNAME")
-	password := os.Getenv("COGNITO_PASSWORD")
-	deviceGroupKey := os.Getenv("COGNITO_DEVICE_GROUP_KEY")
-	deviceKey := os.Getenv("COGNITO_DEVICE_KEY")
-	devicePassword := os.Getenv("COGNITO_DEVICE_PASSWORD")
-
-	auth := cognito.NewCognito(cognito.AuthParams{
-		Username:       username,
-		Password:       password,
-		DeviceGroupKey: deviceGroupKey,
-		DeviceKey:      deviceKey,
-		DevicePassword: devicePassword,
-	})
-
-	client := extend.New(auth)
+func createVirtualCard(displayName, balanceCents string) (*extend.VirtualCard, *extend.RevealedCard, error) {
+	client := newClient()
 
 	balance, err := strconv.Atoi(balanceCents)
 	if err != nil {
-		return nil, fmt.Errorf("invalid balance: %v", err)
+		return nil, nil, fmt.Errorf("invalid balance: %v", err)
+	}
+
+	recipient, err := resolveRecipient(context.Background(), client, os.Getenv("RECIPIENT"))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	initialCard, err := client.CreateVirtualCard(context.Background(), extend.CreateVirtualCardOptions{
+	card, err := client.CreateVirtualCard(context.Background(), extend.CreateVirtualCardOptions{
 		CreditCardID: os.Getenv("CREDIT_CARD_ID"),
 		DisplayName:  displayName,
 		BalanceCents: balance,
 		Currency:     extend.CurrencyUSD,
 		ValidTo:      time.Now().AddDate(0, 1, 0),
-		Recipient:    os.Getenv("RECIPIENT"),
+		Recipient:    recipient,
 		Notes:        "",
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	card, err := client.GetVirtualCard(context.Background(), initialCard.ID)
+
+	// The card number isn't returned on creation
+	revealed, err := client.RevealVirtualCard(context.Background(), card.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get virtual card: %v", err)
+		return nil, nil, fmt.Errorf("failed to reveal virtual card: %v", err)
 	}
 
-	return card, nil
+	return card, revealed, nil
 }
 
-func formatLimit(cents int) string {
-	return fmt.Sprintf("$%.2f", float64(cents)/100)
+// needsAccount reports whether the bot must know its Extend user, to check
+// the expected organization or role or to resolve RECIPIENT by name
+func needsAccount() bool {
+	recipient := os.Getenv("RECIPIENT")
+	return os.Getenv("EXPECTED_ORGANIZATION_ID") != "" ||
+		os.Getenv("EXPECTED_ROLE") != "" ||
+		(recipient != "" && !strings.Contains(recipient, "@"))
 }
 
-func handleCardClosure(s *discordgo.Session, m *discordgo.MessageCreate, vcID string) error {
+// checkAccount makes sure the configured credentials belong to the expected
+// organization and role, when EXPECTED_ORGANIZATION_ID and EXPECTED_ROLE are
+// set
+func checkAccount(ctx context.Context) (*extend.User, error) {
+	user, err := newClient().GetCurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if expected := os.Getenv("EXPECTED_ORGANIZATION_ID"); expected != "" && user.OrganizationID != expected {
+		return nil, fmt.Errorf("%s belongs to organization %s, expected %s", user.Email, user.OrganizationID, expected)
+	}
+	if expected := os.Getenv("EXPECTED_ROLE"); expected != "" && !strings.EqualFold(user.OrganizationRole, expected) {
+		return nil, fmt.Errorf("%s has role %s, expected %s", user.Email, user.OrganizationRole, expected)
+	}
 
+	return user, nil
+}
+
+// resolveRecipient returns the email of a recipient given by email or by
+// the name of a member of the organization
+func resolveRecipient(ctx context.Context, client *extend.Client, recipient string) (string, error) {
+	if recipient == "" || strings.Contains(recipient, "@") {
+		return recipient, nil
+	}
+
+	member, err := client.FindOrganizationMember(ctx, account.OrganizationID, recipient)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve recipient: %w", err)
+	}
+	return member.Email, nil
+}
+
+func newClient() *extend.Client {
 	username := os.Getenv("COGNITO_USERNAME")
 	password := os.Getenv("COGNITO_PASSWORD")
 	deviceGroupKey := os.Getenv("COGNITO_DEVICE_GROUP_KEY")
@@ -207,10 +263,33 @@ func handleCardClosure(s *discordgo.Session, m *discordgo.MessageCreate, vcID st
 		DeviceKey:      deviceKey,
 		DevicePassword: devicePassword,
 	})
+	auth.SetLogger(slog.Default())
+	auth.SetAuthObserver(botMetrics.ObserveAuth)
+	botMetrics.WatchAuthenticator("bot", auth)
+
+	return extend.New(auth,
+		extend.WithLogger(slog.Default()),
+		extend.WithMiddleware(botMetrics.Middleware),
+		extend.WithIdempotencyJournal(createJournal),
+		extend.WithRevealAudit(func(ctx context.Context, event extend.RevealEvent) {
+			if event.Err == nil {
+				slog.InfoContext(ctx, "card number sent to discord", "card_id", event.VirtualCardID)
+			}
+		}),
+	)
+}
 
-	client := extend.New(auth)
+func formatLimit(cents int) string {
+	return fmt.Sprintf("$%.2f", float64(cents)/100)
+}
+
+func handleCardClosure(s *discordgo.Session, m *discordgo.MessageCreate, vcID string) error {
+	client := newClient()
 
 	_, err := client.CloseVirtualCard(context.Background(), vcID)
+	if errors.Is(err, extend.ErrNotFound) {
+		return fmt.Errorf("no virtual card with ID %s", vcID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to close virtual card: %v", err)
 	}
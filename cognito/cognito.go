@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
 	"local/extend"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -18,6 +21,11 @@ var (
 	userPoolName = "pN4CuZHEc"
 )
 
+const (
+	// DefaultEndpoint is the Cognito identity provider used by Extend
+	DefaultEndpoint = "https://cognito-idp.us-east-1.amazonaws.com/"
+)
+
 type Cognito struct {
 	csrp *srpAuthentication
 
@@ -25,9 +33,17 @@ type Cognito struct {
 	refreshToken string
 	expiry       time.Time
 
-	http *http.Client
+	endpoint string
+	http     *http.Client
+	logger   *slog.Logger
+	observer func(AuthEvent)
 
-	mu sync.Mutex
+	tracerProvider trace.TracerProvider
+
+	// mu serializes logins and refreshes, tokenMu guards the tokens so they
+	// can be read while one is running
+	mu      sync.Mutex
+	tokenMu sync.Mutex
 }
 
 var (
@@ -37,12 +53,34 @@ var (
 func NewCognito(auth AuthParams) *Cognito {
 	csrp := newSRP(auth)
 	return &Cognito{
-		csrp: csrp,
-		http: http.DefaultClient,
+		csrp:     csrp,
+		endpoint: DefaultEndpoint,
+		http:     http.DefaultClient,
 	}
 }
 
 func (c *Cognito) Expiry() time.Time {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.expiry
+}
+
+func (c *Cognito) tokens() (accessToken, refreshToken string, expiry time.Time) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.accessToken, c.refreshToken, c.expiry
+}
+
+// setTokens stores the tokens of a login or refresh, an empty refresh token
+// keeps the current one
+func (c *Cognito) setTokens(accessToken, refreshToken string, expiresIn int) time.Time {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.accessToken = accessToken
+	if refreshToken != "" {
+		c.refreshToken = refreshToken
+	}
+	c.expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
 	return c.expiry
 }
 
@@ -50,13 +88,18 @@ func (c *Cognito) SetHTTPClient(http *http.Client) {
 	c.http = http
 }
 
+// SetEndpoint points Cognito at another identity provider, e.g. a local fake
+func (c *Cognito) SetEndpoint(endpoint string) {
+	c.endpoint = endpoint
+}
+
 func (c *Cognito) request(ctx context.Context, target string, body any, response any) error {
 	payload, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://cognito-idp.us-east-1.amazonaws.com/", bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
@@ -97,7 +140,39 @@ func (c *Cognito) request(ctx context.Context, target string, body any, response
 }
 
 func (c *Cognito) Login(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.signIn(ctx)
+}
+
+// signIn logs in with c.mu held
+func (c *Cognito) signIn(ctx context.Context) (_ string, err error) {
+	start := time.Now()
+	defer func() { c.observe(AuthEventLogin, start, err) }()
+
+	ctx, span := c.startSpan(ctx, "Login")
+	defer func() { endSpan(span, err) }()
+
+	accessToken, err := c.login(ctx)
+	if err != nil {
+		c.log(ctx, slog.LevelError, "cognito login failed",
+			slog.Duration("latency", time.Since(start)),
+			slog.String("error", err.Error()),
+		)
+		return "", err
+	}
+
+	c.log(ctx, slog.LevelInfo, "cognito login",
+		slog.Duration("latency", time.Since(start)),
+		slog.Time("expiry", c.Expiry()),
+	)
+	return accessToken, nil
+}
+
+func (c *Cognito) login(ctx context.Context) (string, error) {
+	start := time.Now()
 	userChallenge, err := c.userSrpAuth(ctx)
+	c.logStep(ctx, "USER_SRP_AUTH", start, err)
 	if err != nil {
 		return "", fmt.Errorf("user auth: %w", err)
 	}
@@ -106,7 +181,9 @@ func (c *Cognito) Login(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("unexpected user challenge name: %s", userChallenge.ChallengeName)
 	}
 
+	start = time.Now()
 	deviceAuth, err := c.userPasswordVerifier(ctx, userChallenge.ChallengeParameters)
+	c.logStep(ctx, "PASSWORD_VERIFIER", start, err)
 	if err != nil {
 		return "", fmt.Errorf("user password verifier: %w", err)
 	}
@@ -115,21 +192,23 @@ func (c *Cognito) Login(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("unexpected device challenge name: %s", deviceAuth.ChallengeName)
 	}
 
+	start = time.Now()
 	deviceChallenge, err := c.deviceSrpAuth(ctx, deviceAuth.Session)
+	c.logStep(ctx, "DEVICE_SRP_AUTH", start, err)
 	if err != nil {
 		return "", fmt.Errorf("device srp auth: %w", err)
 	}
 
+	start = time.Now()
 	tokens, err := c.devicePasswordVerifier(ctx, userChallenge.ChallengeParameters["USER_ID_FOR_SRP"], deviceChallenge.ChallengeParameters)
+	c.logStep(ctx, "DEVICE_PASSWORD_VERIFIER", start, err)
 	if err != nil {
 		return "", fmt.Errorf("device password verifier: %w", err)
 	}
 
-	c.accessToken = tokens.AuthenticationResult.AccessToken
-	c.refreshToken = tokens.AuthenticationResult.RefreshToken
-	c.expiry = time.Now().Add(time.Duration(tokens.AuthenticationResult.ExpiresIn) * time.Second)
-
-	return c.accessToken, nil
+	result := tokens.AuthenticationResult
+	c.setTokens(result.AccessToken, result.RefreshToken, result.ExpiresIn)
+	return result.AccessToken, nil
 }
 
 type refreshAuthParameters struct {
@@ -156,22 +235,44 @@ type refreshResponse struct {
 }
 
 func (c *Cognito) Refresh(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refresh(ctx)
+}
+
+// refresh renews the access token with c.mu held
+func (c *Cognito) refresh(ctx context.Context) (_ string, err error) {
+	start := time.Now()
+	defer func() { c.observe(AuthEventRefresh, start, err) }()
+
+	ctx, span := c.startSpan(ctx, "Refresh")
+	defer func() { endSpan(span, err) }()
+
+	_, refreshToken, _ := c.tokens()
 	var res refreshResponse
-	err := c.request(ctx, "AWSCognitoIdentityProviderService.InitiateAuth", refreshPayload{
+	err = c.request(ctx, "AWSCognitoIdentityProviderService.InitiateAuth", refreshPayload{
 		ClientId: clientId,
 		AuthFlow: "REFRESH_TOKEN_AUTH",
 		AuthParameters: refreshAuthParameters{
-			RefreshToken: c.refreshToken,
+			RefreshToken: refreshToken,
 			DeviceKey:    c.csrp.auth.DeviceKey,
 		},
 		ClientMetadata: struct{}{},
 	}, &res)
 	if err != nil {
+		c.log(ctx, slog.LevelError, "cognito refresh failed",
+			slog.Duration("latency", time.Since(start)),
+			slog.String("error", err.Error()),
+		)
 		return "", err
 	}
 
-	c.accessToken = res.AuthenticationResult.AccessToken
-	c.expiry = time.Now().Add(time.Duration(res.AuthenticationResult.ExpiresIn) * time.Second)
+	expiry := c.setTokens(res.AuthenticationResult.AccessToken, "", res.AuthenticationResult.ExpiresIn)
+
+	c.log(ctx, slog.LevelInfo, "cognito refresh",
+		slog.Duration("latency", time.Since(start)),
+		slog.Time("expiry", expiry),
+	)
 
 	return res.AuthenticationResult.AccessToken, nil
 }
@@ -179,15 +280,30 @@ func (c *Cognito) Refresh(ctx context.Context) (string, error) {
 func (c *Cognito) GetAccessToken(ctx context.Context) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.refreshToken == "" {
-		return c.Login(ctx)
+
+	accessToken, refreshToken, expiry := c.tokens()
+	if refreshToken == "" {
+		return c.signIn(ctx)
 	}
 
-	if expiresSoon(c.expiry) {
-		return c.Refresh(ctx)
+	if expiresSoon(expiry) {
+		accessToken, err := c.refresh(ctx)
+		if err != nil {
+			// The refresh token may have expired or been revoked
+			return c.signIn(ctx)
+		}
+		return accessToken, nil
 	}
 
-	return c.accessToken, nil
+	return accessToken, nil
+}
+
+func (c *Cognito) Invalidate(accessToken string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if accessToken == c.accessToken {
+		c.expiry = time.Time{}
+	}
 }
 
 func expiresSoon(expiry time.Time) bool {
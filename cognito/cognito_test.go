@@ -0,0 +1,133 @@
+package cognito_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"local/extend/cognito"
+	"local/extend/cognito/cognitotest"
+)
+
+var testParams = cognito.AuthParams{
+	Username:       "bot@example.com",
+	Password:       "hunter2",
+	DeviceKey:      "us-east-1_device",
+	DevicePassword: "device-password",
+	DeviceGroupKey: "-group",
+}
+
+func newTestCognito(t *testing.T) (*cognitotest.Server, *cognito.Cognito) {
+	t.Helper()
+	server := cognitotest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddUser(testParams)
+	return server, server.NewCognito(testParams)
+}
+
+func TestGetAccessToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// before runs after a first GetAccessToken
+		before    func(server *cognitotest.Server, c *cognito.Cognito, token string)
+		logins    int
+		refreshes int
+	}{
+		{
+			name:   "cached",
+			before: func(*cognitotest.Server, *cognito.Cognito, string) {},
+			logins: 1,
+		},
+		{
+			name: "invalidated",
+			before: func(_ *cognitotest.Server, c *cognito.Cognito, token string) {
+				c.Invalidate(token)
+			},
+			logins:    1,
+			refreshes: 1,
+		},
+		{
+			name: "invalidated with another token",
+			before: func(_ *cognitotest.Server, c *cognito.Cognito, _ string) {
+				c.Invalidate("stale")
+			},
+			logins: 1,
+		},
+		{
+			name: "refresh token revoked",
+			before: func(server *cognitotest.Server, c *cognito.Cognito, token string) {
+				server.RevokeRefreshTokens()
+				c.Invalidate(token)
+			},
+			logins:    2,
+			refreshes: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, c := newTestCognito(t)
+			ctx := context.Background()
+
+			token, err := c.GetAccessToken(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.before(server, c, token)
+
+			token, err = c.GetAccessToken(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !server.ValidAccessToken(token) {
+				t.Errorf("access token %q isn't valid", token)
+			}
+			if got := server.Calls("USER_SRP_AUTH"); got != test.logins {
+				t.Errorf("got %d logins, want %d", got, test.logins)
+			}
+			if got := server.Calls("REFRESH_TOKEN_AUTH"); got != test.refreshes {
+				t.Errorf("got %d refreshes, want %d", got, test.refreshes)
+			}
+			if !c.Expiry().After(time.Now()) {
+				t.Errorf("expiry %s isn't in the future", c.Expiry())
+			}
+		})
+	}
+}
+
+// TestConcurrentUse is meant for go test -race: the expiry is read by
+// metrics scrapes while requests log in, refresh and invalidate tokens
+func TestConcurrentUse(t *testing.T) {
+	_, c := newTestCognito(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			token, err := c.GetAccessToken(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Invalidate(token)
+		}()
+		go func() {
+			defer wg.Done()
+			c.Expiry()
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.Login(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Fails until a login stored a refresh token
+			c.Refresh(ctx)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,420 @@
+// Package cognitotest runs a fake Cognito identity provider implementing
+// the flows used by package cognito: USER_SRP_AUTH followed by the
+// PASSWORD_VERIFIER, DEVICE_SRP_AUTH and DEVICE_PASSWORD_VERIFIER
+// challenges, and REFRESH_TOKEN_AUTH. SRP proofs are verified from the
+// server side, so wrong passwords and device secrets are rejected.
+//
+//	idp := cognitotest.NewServer()
+//	defer idp.Close()
+//
+//	idp.AddUser(params)
+//	auth := idp.NewCognito(params)
+//	token, err := auth.GetAccessToken(ctx)
+package cognitotest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"local/extend/cognito"
+)
+
+// The app client and user pool of Extend, which the client in package
+// cognito always uses
+const (
+	ClientID     = "79k2g0t0ujq2tfchb23d5j6htk"
+	UserPoolName = "pN4CuZHEc"
+)
+
+var (
+	DefaultAccessTokenTTL  = time.Hour
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type user struct {
+	username       string
+	userID         string
+	password       verifier
+	deviceKey      string
+	deviceGroupKey string
+	device         verifier
+}
+
+type token struct {
+	username string
+	expiry   time.Time
+}
+
+type Server struct {
+	*httptest.Server
+
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of issued tokens
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	mu            sync.Mutex
+	now           func() time.Time
+	users         map[string]*user
+	challenges    map[string]*pendingChallenge
+	sessions      map[string]string
+	accessTokens  map[string]token
+	refreshTokens map[string]token
+	calls         map[string]int
+}
+
+// pendingChallenge is an SRP exchange waiting for the password verifier,
+// keyed by its secret block since the client doesn't send a session
+type pendingChallenge struct {
+	user   *user
+	device bool
+	srp    *challenge
+}
+
+func NewServer() *Server {
+	s := &Server{
+		AccessTokenTTL:  DefaultAccessTokenTTL,
+		RefreshTokenTTL: DefaultRefreshTokenTTL,
+		now:             time.Now,
+		users:           make(map[string]*user),
+		challenges:      make(map[string]*pendingChallenge),
+		sessions:        make(map[string]string),
+		accessTokens:    make(map[string]token),
+		refreshTokens:   make(map[string]token),
+		calls:           make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddUser registers a user and its remembered device. Only verifiers are
+// kept, like a real identity provider.
+func (s *Server) AddUser(params cognito.AuthParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID := "user-" + randomHex(8)
+	s.users[params.Username] = &user{
+		username:       params.Username,
+		userID:         userID,
+		password:       newVerifier(UserPoolName, userID, params.Password),
+		deviceKey:      params.DeviceKey,
+		deviceGroupKey: params.DeviceGroupKey,
+		device:         newVerifier(params.DeviceGroupKey, params.DeviceKey, params.DevicePassword),
+	}
+}
+
+// NewCognito returns a client for the user with params, talking to the
+// server
+func (s *Server) NewCognito(params cognito.AuthParams) *cognito.Cognito {
+	c := cognito.NewCognito(params)
+	c.SetEndpoint(s.URL)
+	c.SetHTTPClient(s.Client())
+	return c
+}
+
+// SetClock replaces the clock used to issue and expire tokens
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// ValidAccessToken reports whether token was issued by the server and hasn't
+// expired or been revoked
+func (s *Server) ValidAccessToken(accessToken string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.accessTokens[accessToken]
+	return ok && s.now().Before(t.expiry)
+}
+
+// RevokeAccessTokens invalidates every access token issued so far, refresh
+// tokens stay valid
+func (s *Server) RevokeAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = make(map[string]token)
+}
+
+// RevokeRefreshTokens invalidates every refresh token issued so far, forcing
+// clients to log in again
+func (s *Server) RevokeRefreshTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshTokens = make(map[string]token)
+}
+
+// Calls returns the number of requests received for an auth flow or
+// challenge, e.g. "USER_SRP_AUTH" or "REFRESH_TOKEN_AUTH"
+func (s *Server) Calls(flow string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[flow]
+}
+
+type initiateAuthRequest struct {
+	AuthFlow       string            `json:"AuthFlow"`
+	ClientID       string            `json:"ClientId"`
+	AuthParameters map[string]string `json:"AuthParameters"`
+}
+
+type respondToAuthChallengeRequest struct {
+	ChallengeName      string            `json:"ChallengeName"`
+	ClientID           string            `json:"ClientId"`
+	ChallengeResponses map[string]string `json:"ChallengeResponses"`
+	Session            string            `json:"Session"`
+}
+
+type authenticationResult struct {
+	AccessToken  string `json:"AccessToken"`
+	IdToken      string `json:"IdToken"`
+	RefreshToken string `json:"RefreshToken,omitempty"`
+	ExpiresIn    int    `json:"ExpiresIn"`
+	TokenType    string `json:"TokenType"`
+}
+
+type authResponse struct {
+	ChallengeName        string                `json:"ChallengeName,omitempty"`
+	ChallengeParameters  map[string]string     `json:"ChallengeParameters"`
+	Session              string                `json:"Session,omitempty"`
+	AuthenticationResult *authenticationResult `json:"AuthenticationResult,omitempty"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.Method != http.MethodPost {
+		writeError(w, "InvalidParameterException", "expected a POST with a JSON body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "AWSCognitoIdentityProviderService.InitiateAuth":
+		var req initiateAuthRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, "InvalidParameterException", "invalid JSON body")
+			return
+		}
+		if req.ClientID != ClientID {
+			writeError(w, "ResourceNotFoundException", "User pool client "+req.ClientID+" does not exist.")
+			return
+		}
+		s.calls[req.AuthFlow]++
+		switch req.AuthFlow {
+		case "USER_SRP_AUTH":
+			s.userSrpAuth(w, req.AuthParameters)
+		case "REFRESH_TOKEN_AUTH":
+			s.refreshTokenAuth(w, req.AuthParameters)
+		default:
+			writeError(w, "InvalidParameterException", "unsupported auth flow "+req.AuthFlow)
+		}
+	case "AWSCognitoIdentityProviderService.RespondToAuthChallenge":
+		var req respondToAuthChallengeRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, "InvalidParameterException", "invalid JSON body")
+			return
+		}
+		if req.ClientID != ClientID {
+			writeError(w, "ResourceNotFoundException", "User pool client "+req.ClientID+" does not exist.")
+			return
+		}
+		s.calls[req.ChallengeName]++
+		switch req.ChallengeName {
+		case "PASSWORD_VERIFIER":
+			s.passwordVerifier(w, req.ChallengeResponses)
+		case "DEVICE_SRP_AUTH":
+			s.deviceSrpAuth(w, req.ChallengeResponses, req.Session)
+		case "DEVICE_PASSWORD_VERIFIER":
+			s.devicePasswordVerifier(w, req.ChallengeResponses)
+		default:
+			writeError(w, "InvalidParameterException", "unsupported challenge "+req.ChallengeName)
+		}
+	default:
+		writeError(w, "UnknownOperationException", "unsupported target "+r.Header.Get("X-Amz-Target"))
+	}
+}
+
+func (s *Server) userSrpAuth(w http.ResponseWriter, params map[string]string) {
+	u, ok := s.users[params["USERNAME"]]
+	if !ok {
+		writeError(w, "UserNotFoundException", "User does not exist.")
+		return
+	}
+
+	srp, err := newChallenge(u.password, params["SRP_A"])
+	if err != nil {
+		writeError(w, "InvalidParameterException", err.Error())
+		return
+	}
+
+	parameters := srp.parameters()
+	s.challenges[parameters["SECRET_BLOCK"]] = &pendingChallenge{user: u, srp: srp}
+
+	parameters["USERNAME"] = u.username
+	parameters["USER_ID_FOR_SRP"] = u.userID
+	writeJSON(w, authResponse{
+		ChallengeName:       "PASSWORD_VERIFIER",
+		ChallengeParameters: parameters,
+	})
+}
+
+func (s *Server) passwordVerifier(w http.ResponseWriter, responses map[string]string) {
+	pending := s.takeChallenge(responses["PASSWORD_CLAIM_SECRET_BLOCK"], false)
+	if pending == nil {
+		writeError(w, "NotAuthorizedException", "Invalid secret block.")
+		return
+	}
+
+	u := pending.user
+	if !pending.srp.verify(UserPoolName, u.userID, responses["TIMESTAMP"], responses["PASSWORD_CLAIM_SIGNATURE"]) {
+		writeError(w, "NotAuthorizedException", "Incorrect username or password.")
+		return
+	}
+
+	if responses["DEVICE_KEY"] != u.deviceKey {
+		writeError(w, "NotAuthorizedException", "Device does not exist.")
+		return
+	}
+
+	session := randomHex(32)
+	s.sessions[session] = u.username
+	writeJSON(w, authResponse{
+		ChallengeName:       "DEVICE_SRP_AUTH",
+		ChallengeParameters: map[string]string{},
+		Session:             session,
+	})
+}
+
+func (s *Server) deviceSrpAuth(w http.ResponseWriter, responses map[string]string, session string) {
+	username, ok := s.sessions[session]
+	delete(s.sessions, session)
+	if !ok || username != responses["USERNAME"] {
+		writeError(w, "NotAuthorizedException", "Invalid session for the user.")
+		return
+	}
+
+	u := s.users[username]
+	if responses["DEVICE_KEY"] != u.deviceKey {
+		writeError(w, "NotAuthorizedException", "Device does not exist.")
+		return
+	}
+
+	srp, err := newChallenge(u.device, responses["SRP_A"])
+	if err != nil {
+		writeError(w, "InvalidParameterException", err.Error())
+		return
+	}
+
+	parameters := srp.parameters()
+	s.challenges[parameters["SECRET_BLOCK"]] = &pendingChallenge{user: u, device: true, srp: srp}
+
+	parameters["USERNAME"] = u.userID
+	writeJSON(w, authResponse{
+		ChallengeName:       "DEVICE_PASSWORD_VERIFIER",
+		ChallengeParameters: parameters,
+	})
+}
+
+func (s *Server) devicePasswordVerifier(w http.ResponseWriter, responses map[string]string) {
+	pending := s.takeChallenge(responses["PASSWORD_CLAIM_SECRET_BLOCK"], true)
+	if pending == nil {
+		writeError(w, "NotAuthorizedException", "Invalid secret block.")
+		return
+	}
+
+	u := pending.user
+	if responses["USERNAME"] != u.userID || responses["DEVICE_KEY"] != u.deviceKey {
+		writeError(w, "NotAuthorizedException", "Incorrect username or device.")
+		return
+	}
+	if !pending.srp.verify(u.deviceGroupKey, u.deviceKey, responses["TIMESTAMP"], responses["PASSWORD_CLAIM_SIGNATURE"]) {
+		writeError(w, "NotAuthorizedException", "Incorrect device password.")
+		return
+	}
+
+	writeJSON(w, authResponse{
+		ChallengeParameters:  map[string]string{},
+		AuthenticationResult: s.issueTokens(u, true),
+	})
+}
+
+func (s *Server) refreshTokenAuth(w http.ResponseWriter, params map[string]string) {
+	t, ok := s.refreshTokens[params["REFRESH_TOKEN"]]
+	if !ok {
+		writeError(w, "NotAuthorizedException", "Invalid Refresh Token")
+		return
+	}
+	if !s.now().Before(t.expiry) {
+		delete(s.refreshTokens, params["REFRESH_TOKEN"])
+		writeError(w, "NotAuthorizedException", "Refresh Token has expired")
+		return
+	}
+
+	u := s.users[t.username]
+	if params["DEVICE_KEY"] != u.deviceKey {
+		writeError(w, "NotAuthorizedException", "Invalid Refresh Token")
+		return
+	}
+
+	writeJSON(w, authResponse{
+		ChallengeParameters:  map[string]string{},
+		AuthenticationResult: s.issueTokens(u, false),
+	})
+}
+
+func (s *Server) takeChallenge(secretBlock string, device bool) *pendingChallenge {
+	pending, ok := s.challenges[secretBlock]
+	if !ok || pending.device != device {
+		return nil
+	}
+	delete(s.challenges, secretBlock)
+	return pending
+}
+
+func (s *Server) issueTokens(u *user, withRefreshToken bool) *authenticationResult {
+	now := s.now()
+	result := &authenticationResult{
+		AccessToken: randomHex(32),
+		IdToken:     randomHex(32),
+		ExpiresIn:   int(s.AccessTokenTTL / time.Second),
+		TokenType:   "Bearer",
+	}
+	s.accessTokens[result.AccessToken] = token{username: u.username, expiry: now.Add(s.AccessTokenTTL)}
+
+	if withRefreshToken {
+		result.RefreshToken = randomHex(32)
+		s.refreshTokens[result.RefreshToken] = token{username: u.username, expiry: now.Add(s.RefreshTokenTTL)}
+	}
+
+	return result
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError answers like Cognito, with a 400 and the exception type
+func writeError(w http.ResponseWriter, errorType string, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Header().Set("X-Amzn-Errortype", errorType)
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"__type":  errorType,
+		"message": message,
+	})
+}
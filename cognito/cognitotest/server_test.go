@@ -0,0 +1,99 @@
+package cognitotest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"local/extend/cognito"
+	"local/extend/cognito/cognitotest"
+)
+
+var params = cognito.AuthParams{
+	Username:       "bot@example.com",
+	Password:       "hunter2",
+	DeviceKey:      "us-east-1_device",
+	DevicePassword: "device-password",
+	DeviceGroupKey: "-group",
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(p *cognito.AuthParams)
+		// wantErr is part of the error message, empty for a successful login
+		wantErr string
+	}{
+		{"correct password", func(*cognito.AuthParams) {}, ""},
+		{"unknown user", func(p *cognito.AuthParams) { p.Username = "someone@example.com" }, "User does not exist."},
+		{"wrong password", func(p *cognito.AuthParams) { p.Password = "hunter3" }, "Incorrect username or password."},
+		{"unknown device", func(p *cognito.AuthParams) { p.DeviceKey = "us-east-1_other" }, "Device does not exist."},
+		{"wrong device password", func(p *cognito.AuthParams) { p.DevicePassword = "other-password" }, "Incorrect device password."},
+		{"wrong device group", func(p *cognito.AuthParams) { p.DeviceGroupKey = "-other" }, "Incorrect device password."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := cognitotest.NewServer()
+			defer server.Close()
+			server.AddUser(params)
+
+			login := params
+			test.change(&login)
+			token, err := server.NewCognito(login).Login(context.Background())
+
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatal(err)
+			case test.wantErr == "" && !server.ValidAccessToken(token):
+				t.Errorf("access token %q isn't valid", token)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenLifetimes(t *testing.T) {
+	tests := []struct {
+		name string
+		// elapsed is the time passed since the login
+		elapsed      time.Duration
+		validAccess  bool
+		refreshError bool
+	}{
+		{"fresh", time.Minute, true, false},
+		{"access token expired", 2 * time.Hour, false, false},
+		{"refresh token expired", 31 * 24 * time.Hour, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := cognitotest.NewServer()
+			defer server.Close()
+			server.AddUser(params)
+			now := time.Now()
+			server.SetClock(func() time.Time { return now })
+
+			c := server.NewCognito(params)
+			ctx := context.Background()
+			token, err := c.Login(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now = now.Add(test.elapsed)
+			if valid := server.ValidAccessToken(token); valid != test.validAccess {
+				t.Errorf("got valid access token %t, want %t", valid, test.validAccess)
+			}
+			refreshed, err := c.Refresh(ctx)
+			if refreshError := err != nil; refreshError != test.refreshError {
+				t.Fatalf("got refresh error %v", err)
+			}
+			if err == nil && !server.ValidAccessToken(refreshed) {
+				t.Errorf("refreshed access token %q isn't valid", refreshed)
+			}
+		})
+	}
+}
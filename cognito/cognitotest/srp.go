@@ -0,0 +1,164 @@
+package cognitotest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The group parameters are fixed by Cognito. The key derivation below is
+// written from the SRP-6a and HKDF definitions rather than shared with
+// package cognito, so the fake checks the math of the client it serves.
+const (
+	nHex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+		"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+		"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+		"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+		"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+		"15728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64" +
+		"ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6B" +
+		"F12FFA06D98A0864D87602733EC86A64521F2B18177B200C" +
+		"BBE117577A615D6C770988C0BAD946E208E24FA074E5AB31" +
+		"43DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+	derivedKeyInfo = "Caldera Derived Key"
+)
+
+var (
+	bigN, _ = new(big.Int).SetString(nHex, 16)
+	g       = big.NewInt(2)
+	// k = H(N | g)
+	k = new(big.Int).SetBytes(hash(signedBytes(bigN), signedBytes(g)))
+)
+
+// verifier is what the server stores instead of a password
+type verifier struct {
+	salt *big.Int
+	v    *big.Int
+}
+
+// newVerifier derives the verifier of poolName+username:password, where
+// poolName is the user pool name for users and the device group key for
+// devices
+func newVerifier(poolName string, username string, password string) verifier {
+	salt := randomBig(16)
+	x := calculateX(poolName, username, password, salt)
+	return verifier{
+		salt: salt,
+		v:    new(big.Int).Exp(g, x, bigN),
+	}
+}
+
+// calculateX is H(salt | H(poolName | username | ":" | password))
+func calculateX(poolName string, username string, password string, salt *big.Int) *big.Int {
+	identity := hash([]byte(poolName + username + ":" + password))
+	return new(big.Int).SetBytes(hash(signedBytes(salt), identity))
+}
+
+// challenge is the server half of an SRP exchange
+type challenge struct {
+	verifier    verifier
+	b           *big.Int
+	bigA        *big.Int
+	bigB        *big.Int
+	secretBlock []byte
+}
+
+func newChallenge(ver verifier, srpA string) (*challenge, error) {
+	bigA, ok := new(big.Int).SetString(srpA, 16)
+	if !ok || new(big.Int).Mod(bigA, bigN).Sign() == 0 {
+		return nil, fmt.Errorf("invalid SRP_A")
+	}
+
+	b := randomBig(128)
+	b.Mod(b, bigN)
+	bigB := new(big.Int).Add(new(big.Int).Mul(k, ver.v), new(big.Int).Exp(g, b, bigN))
+	bigB.Mod(bigB, bigN)
+
+	secretBlock := make([]byte, 64)
+	rand.Read(secretBlock)
+
+	return &challenge{
+		verifier:    ver,
+		b:           b,
+		bigA:        bigA,
+		bigB:        bigB,
+		secretBlock: secretBlock,
+	}, nil
+}
+
+// verify checks the signature sent by the client in a password verifier
+// response. The client can only compute it knowing the password.
+func (c *challenge) verify(poolName string, username string, timestamp string, signatureB64 string) bool {
+	u := new(big.Int).SetBytes(hash(signedBytes(c.bigA), signedBytes(c.bigB)))
+
+	// S = (A * v^u) ^ b mod N
+	s := new(big.Int).Exp(c.verifier.v, u, bigN)
+	s.Mul(s, c.bigA).Mod(s, bigN)
+	s.Exp(s, c.b, bigN)
+
+	key := derivedKey(signedBytes(s), signedBytes(u))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(poolName + username + string(c.secretBlock) + timestamp))
+	expected := mac.Sum(nil)
+
+	signature, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, expected)
+}
+
+func (c *challenge) parameters() map[string]string {
+	return map[string]string{
+		"SALT":         c.verifier.salt.Text(16),
+		"SRP_B":        c.bigB.Text(16),
+		"SECRET_BLOCK": base64.StdEncoding.EncodeToString(c.secretBlock),
+	}
+}
+
+func randomBig(n int) *big.Int {
+	b := make([]byte, n)
+	rand.Read(b)
+	return new(big.Int).SetBytes(b)
+}
+
+// hash is the SHA-256 of the concatenation of parts
+func hash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// signedBytes encodes a non-negative integer like Java's
+// BigInteger.toByteArray, which Cognito hashes: big-endian in as few bytes
+// as possible, with a leading zero byte when the high bit is set
+func signedBytes(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// derivedKey is the 16 byte HKDF-SHA256 of the premaster secret, salted with
+// the scrambling parameter u
+func derivedKey(secret []byte, u []byte) []byte {
+	key := make([]byte, 16)
+	io.ReadFull(hkdf.New(sha256.New, secret, u, []byte(derivedKeyInfo)), key)
+	return key
+}
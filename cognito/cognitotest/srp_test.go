@@ -0,0 +1,35 @@
+package cognitotest
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSignedBytes(t *testing.T) {
+	tests := []struct {
+		n    *big.Int
+		want []byte
+	}{
+		{big.NewInt(0), []byte{0x00}},
+		{big.NewInt(2), []byte{0x02}},
+		{big.NewInt(0x7f), []byte{0x7f}},
+		{big.NewInt(0x80), []byte{0x00, 0x80}},
+		{big.NewInt(0x0fff), []byte{0x0f, 0xff}},
+		{big.NewInt(0x8000), []byte{0x00, 0x80, 0x00}},
+		{big.NewInt(0x7fffff), []byte{0x7f, 0xff, 0xff}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.n.Text(16), func(t *testing.T) {
+			if got := signedBytes(test.n); !bytes.Equal(got, test.want) {
+				t.Errorf("got %x, want %x", got, test.want)
+			}
+		})
+	}
+
+	// N has its high bit set and is 3072 bits long
+	if got := signedBytes(bigN); len(got) != 385 || got[0] != 0 || got[1] != 0xff {
+		t.Errorf("got %d bytes starting with %x for N", len(got), got[:2])
+	}
+}
@@ -16,9 +16,12 @@ type deviceSrpResponse struct {
 	ChallengeParameters map[string]string `json:"ChallengeParameters"`
 }
 
-func (c *Cognito) deviceSrpAuth(ctx context.Context, session string) (*deviceSrpResponse, error) {
+func (c *Cognito) deviceSrpAuth(ctx context.Context, session string) (_ *deviceSrpResponse, err error) {
+	ctx, span := c.startSpan(ctx, "deviceSrpAuth")
+	defer func() { endSpan(span, err) }()
+
 	var res deviceSrpResponse
-	err := c.request(ctx, "AWSCognitoIdentityProviderService.RespondToAuthChallenge", deviceSrpAuth{
+	err = c.request(ctx, "AWSCognitoIdentityProviderService.RespondToAuthChallenge", deviceSrpAuth{
 		ChallengeName:      "DEVICE_SRP_AUTH",
 		ClientID:           clientId,
 		ChallengeResponses: c.csrp.GetDeviceAuthParams(),
@@ -28,6 +31,7 @@ func (c *Cognito) deviceSrpAuth(ctx context.Context, session string) (*deviceSrp
 		return nil, err
 	}
 
+	setChallenge(span, "DEVICE_SRP_AUTH", res.ChallengeName)
 	return &res, nil
 }
 
@@ -48,7 +52,10 @@ type devicePasswordVerifierResponse struct {
 	ChallengeParameters  struct{}                    `json:"ChallengeParameters"`
 }
 
-func (c *Cognito) devicePasswordVerifier(ctx context.Context, userId string, challengeParameters map[string]string) (*devicePasswordVerifierResponse, error) {
+func (c *Cognito) devicePasswordVerifier(ctx context.Context, userId string, challengeParameters map[string]string) (_ *devicePasswordVerifierResponse, err error) {
+	ctx, span := c.startSpan(ctx, "devicePasswordVerifier")
+	defer func() { endSpan(span, err) }()
+
 	challengeResponses, err := c.csrp.DevicePasswordVerifierChallenge(userId, challengeParameters)
 	if err != nil {
 		return nil, err
@@ -64,5 +71,6 @@ func (c *Cognito) devicePasswordVerifier(ctx context.Context, userId string, cha
 		return nil, err
 	}
 
+	setChallenge(span, "DEVICE_PASSWORD_VERIFIER", "")
 	return &res, nil
 }
@@ -0,0 +1,65 @@
+package cognito
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"local/extend"
+)
+
+// SetLogger makes Cognito log logins, refreshes and every authentication
+// step. Passwords, tokens and SRP values are never logged.
+func (c *Cognito) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+func (c *Cognito) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (c *Cognito) logStep(ctx context.Context, step string, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String("step", step),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		c.log(ctx, slog.LevelError, "cognito step failed", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	c.log(ctx, slog.LevelDebug, "cognito step", attrs...)
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return extend.Redacted
+}
+
+// LogValue keeps passwords and device secrets out of structured logs
+func (a AuthParams) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", a.Username),
+		slog.String("password", redact(a.Password)),
+		slog.String("device_key", a.DeviceKey),
+		slog.String("device_password", redact(a.DevicePassword)),
+		slog.String("device_group_key", a.DeviceGroupKey),
+	)
+}
+
+// String keeps passwords and device secrets out of values printed with fmt
+func (a AuthParams) String() string {
+	return fmt.Sprintf(
+		"{Username:%s Password:%s DeviceKey:%s DevicePassword:%s DeviceGroupKey:%s}",
+		a.Username, redact(a.Password), a.DeviceKey, redact(a.DevicePassword), a.DeviceGroupKey,
+	)
+}
+
+func (a AuthParams) GoString() string {
+	return "cognito.AuthParams" + a.String()
+}
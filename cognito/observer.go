@@ -0,0 +1,44 @@
+package cognito
+
+import (
+	"time"
+)
+
+type AuthEventKind string
+
+const (
+	AuthEventLogin   AuthEventKind = "login"
+	AuthEventRefresh AuthEventKind = "refresh"
+)
+
+// AuthEvent describes a completed login or refresh
+type AuthEvent struct {
+	Kind     AuthEventKind
+	Duration time.Duration
+
+	// Expiry is the expiry of the new access token, zero when Err is set
+	Expiry time.Time
+	Err    error
+}
+
+// SetAuthObserver registers a function called after every login and
+// refresh, e.g. to export metrics
+func (c *Cognito) SetAuthObserver(observer func(AuthEvent)) {
+	c.observer = observer
+}
+
+func (c *Cognito) observe(kind AuthEventKind, start time.Time, err error) {
+	if c.observer == nil {
+		return
+	}
+
+	event := AuthEvent{
+		Kind:     kind,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if err == nil {
+		event.Expiry = c.Expiry()
+	}
+	c.observer(event)
+}
@@ -0,0 +1,45 @@
+package cognito
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "local/extend/cognito"
+
+// SetTracerProvider makes Cognito create a span for logins, refreshes and
+// every SRP step using provider instead of the global provider
+func (c *Cognito) SetTracerProvider(provider trace.TracerProvider) {
+	c.tracerProvider = provider
+}
+
+func (c *Cognito) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	provider := c.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName).Start(ctx, "cognito."+name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// setChallenge records the challenge answered by a step and the challenge
+// Cognito asked for next
+func setChallenge(span trace.Span, challengeName string, nextChallengeName string) {
+	if challengeName != "" {
+		span.SetAttributes(attribute.String("cognito.challenge_name", challengeName))
+	}
+	if nextChallengeName != "" {
+		span.SetAttributes(attribute.String("cognito.next_challenge_name", nextChallengeName))
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -16,9 +16,12 @@ type userSrpResponse struct {
 	ChallengeParameters map[string]string `json:"ChallengeParameters"`
 }
 
-func (c *Cognito) userSrpAuth(ctx context.Context) (*userSrpResponse, error) {
+func (c *Cognito) userSrpAuth(ctx context.Context) (_ *userSrpResponse, err error) {
+	ctx, span := c.startSpan(ctx, "userSrpAuth")
+	defer func() { endSpan(span, err) }()
+
 	var res userSrpResponse
-	err := c.request(ctx, "AWSCognitoIdentityProviderService.InitiateAuth", userSrpAuth{
+	err = c.request(ctx, "AWSCognitoIdentityProviderService.InitiateAuth", userSrpAuth{
 		AuthFlow:       "USER_SRP_AUTH",
 		ClientID:       clientId,
 		AuthParameters: c.csrp.GetAuthParams(),
@@ -28,6 +31,7 @@ func (c *Cognito) userSrpAuth(ctx context.Context) (*userSrpResponse, error) {
 		return nil, err
 	}
 
+	setChallenge(span, "", res.ChallengeName)
 	return &res, nil
 }
 
@@ -44,7 +48,10 @@ type userPasswordVerifierResponse struct {
 	Session             string   `json:"Session"`
 }
 
-func (c *Cognito) userPasswordVerifier(ctx context.Context, challengeParameters map[string]string) (*userPasswordVerifierResponse, error) {
+func (c *Cognito) userPasswordVerifier(ctx context.Context, challengeParameters map[string]string) (_ *userPasswordVerifierResponse, err error) {
+	ctx, span := c.startSpan(ctx, "userPasswordVerifier")
+	defer func() { endSpan(span, err) }()
+
 	challengeResponses, err := c.csrp.PasswordVerifierChallenge(challengeParameters)
 	if err != nil {
 		return nil, err
@@ -61,5 +68,6 @@ func (c *Cognito) userPasswordVerifier(ctx context.Context, challengeParameters
 		return nil, err
 	}
 
+	setChallenge(span, "PASSWORD_VERIFIER", res.ChallengeName)
 	return &res, nil
 }
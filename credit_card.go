@@ -0,0 +1,136 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type CreditCardStatus string
+
+const (
+	CreditCardStatusActive    CreditCardStatus = "ACTIVE"
+	CreditCardStatusPending   CreditCardStatus = "PENDING"
+	CreditCardStatusCancelled CreditCardStatus = "CANCELLED"
+)
+
+type CreditCardFeatures struct {
+	Direct           bool `json:"direct"`
+	Bulk             bool `json:"bulk"`
+	Recurrence       bool `json:"recurrence"`
+	MccControl       bool `json:"mccControl"`
+	Requests         bool `json:"requests"`
+	ReceiptUploads   bool `json:"receiptUploads"`
+	QboReportEnabled bool `json:"qboReportEnabled"`
+}
+
+// VirtualCardCounts are the numbers of virtual cards funded by a credit card
+// in each status
+type VirtualCardCounts struct {
+	Active    int `json:"active"`
+	Cancelled int `json:"cancelled"`
+	Closed    int `json:"closed"`
+	Pending   int `json:"pending"`
+}
+
+// CreditCard is a funding source for virtual cards
+type CreditCard struct {
+	ID     string           `json:"id"`
+	Status CreditCardStatus `json:"status"`
+
+	CardholderID string `json:"cardholderId"`
+	Cardholder   User   `json:"cardholder"`
+
+	DisplayName  string            `json:"displayName"`
+	CompanyName  string            `json:"companyName"`
+	Issuer       VirtualCardIssuer `json:"issuer"`
+	Network      string            `json:"network"`
+	Last4        string            `json:"last4"`
+	NumberFormat string            `json:"numberFormat"`
+	Currency     string            `json:"currency"`
+
+	CreditLimitCents     int `json:"creditLimitCents"`
+	AvailableCreditCents int `json:"availableCreditCents"`
+
+	Features          CreditCardFeatures `json:"features"`
+	VirtualCardCounts VirtualCardCounts  `json:"virtualCardCounts"`
+
+	CreatedAt *Time `json:"createdAt"`
+	UpdatedAt *Time `json:"updatedAt"`
+}
+
+type CreditCardResponse struct {
+	CreditCard CreditCard `json:"creditCard"`
+}
+
+func (c *Client) GetCreditCard(ctx context.Context, id string) (*CreditCard, error) {
+	var response CreditCardResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "GetCreditCard",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/creditcards/%s", id),
+		ResourceID: id,
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.CreditCard, nil
+}
+
+type ListCreditCardsOptions struct {
+	PaginationOptions
+
+	// Statuses filters the credit cards, all are listed when empty
+	Statuses []CreditCardStatus
+}
+
+type ListCreditCardsResponse struct {
+	PaginationResponse
+	CreditCards []CreditCard `json:"creditCards"`
+}
+
+func (r ListCreditCardsResponse) Items() []CreditCard {
+	return r.CreditCards
+}
+
+func (c *Client) ListCreditCards(options *ListCreditCardsOptions) *Paginator[CreditCard, ListCreditCardsResponse] {
+	query := url.Values{}
+	if len(options.Statuses) > 0 {
+		query.Set("statuses", join(options.Statuses, ","))
+	}
+	return newPaginator[CreditCard, ListCreditCardsResponse](c, "ListCreditCards", options.PaginationOptions, "/creditcards", query)
+}
+
+// FindCreditCard returns the active credit card with a display name, ignoring
+// case. It fails with ErrNotFound when there is none and with ErrConflict
+// when several cards have the name.
+func (c *Client) FindCreditCard(ctx context.Context, displayName string) (*CreditCard, error) {
+	var found []CreditCard
+	cards := c.ListCreditCards(&ListCreditCardsOptions{
+		PaginationOptions: PaginationOptions{Count: 100},
+		Statuses:          []CreditCardStatus{CreditCardStatusActive},
+	})
+	for cards.Next() {
+		page, err := cards.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, card := range page.Items() {
+			if strings.EqualFold(card.DisplayName, displayName) {
+				found = append(found, card)
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: no active credit card named %q", ErrNotFound, displayName)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %d active credit cards named %q", ErrConflict, len(found), displayName)
+	}
+}
@@ -0,0 +1,100 @@
+package extend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DryRun is the request a mutating call would have sent in dry-run mode
+type DryRun struct {
+	Operation  string
+	ResourceID string
+	Method     string
+	URL        string
+
+	// Header holds every header but Authorization
+	Header      http.Header
+	ContentType string
+	Body        []byte
+}
+
+// WithDryRun makes every call that would change state, such as
+// CreateVirtualCard or BulkCreateVirtualCards, build and validate its
+// payload, pass it to report and return a synthetic result instead of
+// sending it. Read operations are still sent to the API. report may be nil,
+// in which case the payload is only logged.
+func WithDryRun(report func(DryRun)) Option {
+	return func(c *Client) {
+		c.dryRun = true
+		c.dryRunReport = report
+	}
+}
+
+// DryRunIDPrefix starts the IDs of resources returned by dry-run calls
+const DryRunIDPrefix = "dryrun_"
+
+// simulate reports req and builds its synthetic response. It is called in
+// place of roundTrip for requests that would change state.
+func (c *Client) simulate(ctx context.Context, req *Request) (*Response, error) {
+	header := c.brand.Header.Clone()
+	for key, values := range req.Header {
+		setHeader(header, key, values...)
+	}
+	header.Set("Content-Type", req.ContentType)
+	header.Set("Accept", "application/vnd.paywithextend.v"+c.apiVersion+"+json")
+
+	report := DryRun{
+		Operation:   req.Operation,
+		ResourceID:  req.ResourceID,
+		Method:      req.Method,
+		URL:         c.brand.APIBaseURL + req.Path,
+		Header:      header,
+		ContentType: req.ContentType,
+		Body:        req.Body,
+	}
+
+	c.log(ctx, slog.LevelInfo, "extend dry run",
+		slog.String("operation", req.Operation),
+		slog.String("method", req.Method),
+		slog.String("url", report.URL),
+		slog.Int("body_bytes", len(req.Body)),
+	)
+	if c.dryRunReport != nil {
+		c.dryRunReport(report)
+	}
+
+	res := &Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		DryRun:     true,
+	}
+	if req.synthetic != nil {
+		result, err := req.synthetic(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res.Body, err = json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func dryRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return DryRunIDPrefix + hex.EncodeToString(b)
+}
+
+func dryRunTime(t time.Time) *Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &Time{t.UTC()}
+}
@@ -0,0 +1,135 @@
+package extend_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestDryRun(t *testing.T) {
+	validTo := time.Now().AddDate(0, 1, 0)
+
+	tests := []struct {
+		operation string
+		call      func(ctx context.Context, client *extend.Client, cardID string) (id string, err error)
+		// sent is the number of read requests still sent to the server
+		sent int
+	}{
+		{"CreateVirtualCard", func(ctx context.Context, client *extend.Client, _ string) (string, error) {
+			card, err := client.CreateVirtualCard(ctx, newCardOptions(""))
+			if err != nil {
+				return "", err
+			}
+			return card.ID, nil
+		}, 0},
+		{"UpdateVirtualCard", func(ctx context.Context, client *extend.Client, cardID string) (string, error) {
+			card, err := client.UpdateVirtualCard(ctx, cardID, extend.UpdateVirtualCardOptions{DisplayName: extend.Ptr("Trips")})
+			if err != nil {
+				return "", err
+			}
+			if card.DisplayName != "Trips" {
+				return "", errors.New("update not applied to the synthetic card")
+			}
+			return card.ID, nil
+		}, 1},
+		{"CancelVirtualCard", func(ctx context.Context, client *extend.Client, cardID string) (string, error) {
+			card, err := client.CancelVirtualCard(ctx, cardID)
+			if err != nil {
+				return "", err
+			}
+			return card.ID, nil
+		}, 1},
+		{"CloseVirtualCard", func(ctx context.Context, client *extend.Client, cardID string) (string, error) {
+			card, err := client.CloseVirtualCard(ctx, cardID)
+			if err != nil {
+				return "", err
+			}
+			return card.ID, nil
+		}, 1},
+		{"BulkCreateVirtualCards", func(ctx context.Context, client *extend.Client, _ string) (string, error) {
+			push, err := client.BulkCreateVirtualCards(ctx, "cc_1", []extend.BulkCreateVirtualCard{
+				{CardType: extend.VirtualCardTypeStandard, Recipient: "alice@example.com", DisplayName: "Alice", BalanceCents: 1000, ValidTo: validTo},
+			})
+			if err != nil {
+				return "", err
+			}
+			return push.BulkVirtualCardPush.BulkVirtualCardUploadID, nil
+		}, 0},
+		{"InviteRecipient", func(ctx context.Context, client *extend.Client, _ string) (string, error) {
+			user, err := client.InviteRecipient(ctx, extend.InviteRecipientOptions{Email: "new@example.com", FirstName: "New", LastName: "Recipient"})
+			if err != nil {
+				return "", err
+			}
+			return user.ID, nil
+		}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.operation, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			seeded := server.SeedCard(extend.VirtualCard{
+				DisplayName:  "Travel",
+				CreditCardID: "cc_1",
+				BalanceCents: 1000,
+				ValidTo:      &extend.Time{Time: validTo},
+			})
+
+			var reports []extend.DryRun
+			client := server.Client(extend.WithDryRun(func(report extend.DryRun) {
+				reports = append(reports, report)
+			}))
+
+			id, err := test.call(context.Background(), client, seeded.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(id, extend.DryRunIDPrefix) && id != seeded.ID {
+				t.Errorf("got ID %q, want a %s ID", id, extend.DryRunIDPrefix)
+			}
+
+			if len(reports) != 1 || reports[0].Operation != test.operation {
+				t.Fatalf("got reports %+v, want one for %s", reports, test.operation)
+			}
+			if reports[0].Header.Get("Authorization") != "" {
+				t.Error("the report holds the access token")
+			}
+			requests := server.Requests()
+			if len(requests) != test.sent {
+				t.Errorf("sent %d requests, want %d", len(requests), test.sent)
+			}
+			for _, req := range requests {
+				if req.Method != http.MethodGet {
+					t.Errorf("sent %s %s", req.Method, req.Path)
+				}
+			}
+			if card, _ := server.Card(seeded.ID); card.DisplayName != "Travel" || card.Status != seeded.Status {
+				t.Errorf("dry run changed the card to %+v", card)
+			}
+		})
+	}
+}
+
+func TestDryRunValidates(t *testing.T) {
+	server := extendtest.NewServer()
+	defer server.Close()
+
+	reported := false
+	client := server.Client(extend.WithDryRun(func(extend.DryRun) { reported = true }))
+
+	_, err := client.BulkCreateVirtualCards(context.Background(), "cc_1", []extend.BulkCreateVirtualCard{
+		{CardType: extend.VirtualCardTypeStandard, Recipient: "not an email", DisplayName: "Alice", BalanceCents: 1000, ValidTo: time.Now()},
+	})
+	if !errors.Is(err, extend.ErrValidation) {
+		t.Errorf("got error %v, want %v", err, extend.ErrValidation)
+	}
+	if reported {
+		t.Error("an invalid call was reported")
+	}
+}
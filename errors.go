@@ -0,0 +1,117 @@
+package extend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrBadRequest   = errors.New("extend: bad request")
+	ErrUnauthorized = errors.New("extend: unauthorized")
+	ErrForbidden    = errors.New("extend: forbidden")
+	ErrNotFound     = errors.New("extend: not found")
+	ErrConflict     = errors.New("extend: conflict")
+	ErrValidation   = errors.New("extend: validation failed")
+	ErrRateLimited  = errors.New("extend: rate limited")
+	ErrServer       = errors.New("extend: server error")
+)
+
+// requestIDHeaders are the response headers checked, in order, for an ID
+// identifying the request in Extend's and AWS's logs
+var requestIDHeaders = []string{"X-Request-Id", "X-Amzn-Requestid", "X-Amz-Cf-Id"}
+
+type APIErrorDetail struct {
+	Field        string `json:"field"`
+	Error        string `json:"error"`
+	InvalidValue string `json:"invalidValue"`
+}
+
+// APIError is returned for every non 2xx response of the Extend API. Use
+// errors.Is with the Err* sentinels to check for a category of failure, or
+// errors.As to access the response.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"-"`
+
+	// Status is the HTTP status line, e.g. "404 Not Found"
+	Status string `json:"-"`
+
+	Header    http.Header `json:"-"`
+	RequestID string      `json:"-"`
+
+	// Body is the raw response body
+	Body []byte `json:"-"`
+
+	// Message is the error message returned by Extend, empty when the body
+	// isn't a JSON error
+	Message string           `json:"error"`
+	Details []APIErrorDetail `json:"details"`
+}
+
+func newAPIError(res *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		Body:       body,
+	}
+
+	for _, header := range requestIDHeaders {
+		if id := res.Header.Get(header); id != "" {
+			e.RequestID = id
+			break
+		}
+	}
+
+	// Non JSON bodies (gateway pages, plain text) only keep the raw body
+	_ = json.Unmarshal(body, e)
+
+	return e
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		if len(e.Body) == 0 {
+			return fmt.Sprintf("extend: bad status: %s", e.Status)
+		}
+		return fmt.Sprintf("extend: bad status: %s: %s", e.Status, string(e.Body))
+	}
+
+	message := "extend: " + e.Message
+	for _, detail := range e.Details {
+		message = fmt.Sprintf("%s (%s: %s)", message, detail.Field, detail.Error)
+	}
+	return message
+}
+
+// Is matches the Err* sentinels against the status code of the response.
+// A 400 carrying field details is both ErrBadRequest and ErrValidation.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity ||
+			(e.StatusCode == http.StatusBadRequest && len(e.Details) > 0)
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// validationError reports invalid options before anything is sent
+func validationError(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrValidation}, args...)...)
+}
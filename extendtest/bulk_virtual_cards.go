@@ -0,0 +1,165 @@
+package extendtest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"local/extend"
+)
+
+// bulkCSVColumns is the number of columns of the bulk upload CSV: card type,
+// locale, recipient email, card name, credit limit, active until date, notes
+// and optionally active from date
+const bulkCSVColumns = 7
+
+func (s *Server) bulkVirtualCardPush(w http.ResponseWriter, r *http.Request, creditCardID string, body []byte) {
+	rows, err := readBulkCSV(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := s.timestamp()
+	upload := &extend.BulkVirtualCardUpload{
+		ID:           s.newID("bvcu"),
+		CreditCardID: creditCardID,
+		CreatedAt:    *now,
+		UpdatedAt:    *now,
+	}
+	response := extend.BulkVirtualCardPushResponse{
+		InvalidEmails: []string{},
+	}
+	response.BulkVirtualCardPush.BulkVirtualCardUploadID = upload.ID
+
+	for i, row := range rows {
+		line := i + 2
+
+		if _, err := mail.ParseAddress(row[2]); err != nil || s.rejection(row[2]) != "" {
+			response.InvalidEmails = append(response.InvalidEmails, row[2])
+			continue
+		}
+
+		limit, err := strconv.ParseFloat(row[4], 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("line %d: invalid credit limit %q", line, row[4]))
+			return
+		}
+		validTo, err := time.Parse("01/02/2006", row[5])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("line %d: invalid active until date %q", line, row[5]))
+			return
+		}
+
+		var validFrom *extend.Time
+		if len(row) > bulkCSVColumns && row[bulkCSVColumns] != "" {
+			date, err := time.Parse("01/02/2006", row[bulkCSVColumns])
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("line %d: invalid active from date %q", line, row[bulkCSVColumns]))
+				return
+			}
+			if date.After(validTo) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("line %d: active from date %q is after active until date", line, row[bulkCSVColumns]))
+				return
+			}
+			validFrom = &extend.Time{Time: date}
+		}
+
+		balanceCents := int(math.Round(limit * 100))
+		card := s.addCard(extend.VirtualCard{
+			CardType:     row[0],
+			DisplayName:  row[3],
+			BalanceCents: balanceCents,
+			CreditCardID: creditCardID,
+			ValidFrom:    validFrom,
+			ValidTo:      &extend.Time{Time: validTo},
+		})
+		card.RecipientID, card.Recipient = s.recipient(row[2])
+
+		record := extend.BulkVirtualCardRecord{
+			CreditCardID: creditCardID,
+			Recipient:    row[2],
+			DisplayName:  row[3],
+			BalanceCents: balanceCents,
+			Currency:     card.Currency,
+			ValidToDate:  []int{validTo.Year(), int(validTo.Month()), validTo.Day()},
+			IsPush:       true,
+		}
+		if validFrom != nil {
+			record.ValidFromDate = []int{validFrom.Year(), int(validFrom.Month()), validFrom.Day()}
+		}
+
+		taskID := s.newID("task")
+		response.BulkVirtualCardPush.Tasks = append(response.BulkVirtualCardPush.Tasks, extend.BulkVirtualCardTask{
+			TaskID: taskID,
+			Status: extend.BulkVirtualCardUploadStatusInitiated,
+			Record: record,
+		})
+		upload.Tasks = append(upload.Tasks, extend.BulkVirtualCardUploadTask{
+			TaskID:        taskID,
+			Status:        extend.BulkVirtualCardUploadStatusCompleted,
+			VirtualCardID: card.ID,
+		})
+	}
+
+	s.uploads[upload.ID] = upload
+	response.CsvVirtualCardPush = response.BulkVirtualCardPush
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readBulkCSV extracts the rows of the CSV uploaded in the "file" part of a
+// multipart body, without the header row
+func readBulkCSV(contentType string, body []byte) ([][]string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, errors.New("expected a multipart/form-data body")
+	}
+
+	form := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file part")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		// Every row has as many columns as the header
+		reader := csv.NewReader(part)
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(rows) > 0 && len(rows[0]) != bulkCSVColumns && len(rows[0]) != bulkCSVColumns+1 {
+			return nil, fmt.Errorf("invalid CSV: expected %d or %d columns, got %d", bulkCSVColumns, bulkCSVColumns+1, len(rows[0]))
+		}
+		if len(rows) < 2 {
+			return nil, errors.New("CSV has no virtual cards")
+		}
+		return rows[1:], nil
+	}
+}
+
+func (s *Server) getBulkVirtualCardUpload(w http.ResponseWriter, id string) {
+	upload, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Bulk virtual card upload not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		BulkVirtualCardUpload *extend.BulkVirtualCardUpload `json:"bulkVirtualCardUpload"`
+	}{upload})
+}
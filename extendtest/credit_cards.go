@@ -0,0 +1,101 @@
+package extendtest
+
+import (
+	"net/http"
+	"strings"
+
+	"local/extend"
+)
+
+// SeedCreditCard adds a funding credit card to the server and returns it as
+// stored. Missing fields are filled with an ID, the ACTIVE status, the USD
+// currency and timestamps. The virtual card counts are computed from the
+// cards stored by the server.
+func (s *Server) SeedCreditCard(card extend.CreditCard) extend.CreditCard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card.ID == "" {
+		card.ID = s.newID("cc")
+	}
+	if card.Status == "" {
+		card.Status = extend.CreditCardStatusActive
+	}
+	if card.Currency == "" {
+		card.Currency = string(extend.CurrencyUSD)
+	}
+	if card.CreatedAt == nil {
+		card.CreatedAt = s.timestamp()
+	}
+	if card.UpdatedAt == nil {
+		card.UpdatedAt = card.CreatedAt
+	}
+
+	s.creditCards = append(s.creditCards, &card)
+	return s.withCounts(card)
+}
+
+func (s *Server) findCreditCard(id string) *extend.CreditCard {
+	for _, card := range s.creditCards {
+		if card.ID == id {
+			return card
+		}
+	}
+	return nil
+}
+
+// withCounts sets the virtual card counts of a credit card
+func (s *Server) withCounts(card extend.CreditCard) extend.CreditCard {
+	card.VirtualCardCounts = extend.VirtualCardCounts{}
+	for _, virtualCard := range s.cards {
+		if virtualCard.CreditCardID != card.ID {
+			continue
+		}
+		switch virtualCard.Status {
+		case extend.VirtualCardStatusActive:
+			card.VirtualCardCounts.Active++
+		case extend.VirtualCardStatusCancelled:
+			card.VirtualCardCounts.Cancelled++
+		case extend.VirtualCardStatusClosed:
+			card.VirtualCardCounts.Closed++
+		}
+	}
+	return card
+}
+
+func (s *Server) getCreditCard(w http.ResponseWriter, id string) {
+	card := s.findCreditCard(id)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Credit card not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, extend.CreditCardResponse{CreditCard: s.withCounts(*card)})
+}
+
+func (s *Server) listCreditCards(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	statuses := map[extend.CreditCardStatus]bool{}
+	for _, status := range strings.Split(query.Get("statuses"), ",") {
+		if status != "" {
+			statuses[extend.CreditCardStatus(status)] = true
+		}
+	}
+
+	cards := []extend.CreditCard{}
+	for _, card := range s.creditCards {
+		if len(statuses) == 0 || statuses[card.Status] {
+			cards = append(cards, s.withCounts(*card))
+		}
+	}
+
+	page, pagination, ok := paginate(w, query, len(cards))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, extend.ListCreditCardsResponse{
+		PaginationResponse: extend.PaginationResponse{PaginationData: pagination},
+		CreditCards:        cards[page[0]:page[1]],
+	})
+}
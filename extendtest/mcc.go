@@ -0,0 +1,60 @@
+package extendtest
+
+import "local/extend"
+
+// DefaultMCCGroups are the merchant category groups served by a new server
+var DefaultMCCGroups = []extend.MCCGroup{
+	{
+		ID:   "mccg_travel",
+		Name: "Travel",
+		MCCs: []extend.MCC{
+			{Code: "3000", Description: "United Airlines"},
+			{Code: "4111", Description: "Commuter Transportation"},
+			{Code: "4511", Description: "Airlines"},
+			{Code: "4722", Description: "Travel Agencies"},
+			{Code: "7011", Description: "Lodging"},
+			{Code: "7512", Description: "Car Rental"},
+		},
+	},
+	{
+		ID:   "mccg_software",
+		Name: "Software",
+		MCCs: []extend.MCC{
+			{Code: "5734", Description: "Computer Software Stores"},
+			{Code: "5817", Description: "Digital Goods: Applications"},
+			{Code: "7372", Description: "Computer Programming"},
+		},
+	},
+	{
+		ID:   "mccg_restaurants",
+		Name: "Restaurants",
+		MCCs: []extend.MCC{
+			{Code: "5812", Description: "Eating Places, Restaurants"},
+			{Code: "5814", Description: "Fast Food Restaurants"},
+		},
+	},
+	{
+		ID:   "mccg_fuel",
+		Name: "Fuel",
+		MCCs: []extend.MCC{
+			{Code: "5541", Description: "Service Stations"},
+			{Code: "5542", Description: "Automated Fuel Dispensers"},
+		},
+	},
+}
+
+// SetMCCGroups replaces the merchant category groups served by the server
+func (s *Server) SetMCCGroups(groups []extend.MCCGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mccGroups = groups
+}
+
+func (s *Server) findMCCGroup(id string) *extend.MCCGroup {
+	for i := range s.mccGroups {
+		if s.mccGroups[i].ID == id {
+			return &s.mccGroups[i]
+		}
+	}
+	return nil
+}
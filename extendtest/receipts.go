@@ -0,0 +1,134 @@
+package extendtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+
+	"local/extend"
+)
+
+type receipt struct {
+	attachment extend.ReceiptAttachment
+	content    []byte
+}
+
+// Receipt returns the content of a receipt stored by the server
+func (s *Server) Receipt(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt := s.findReceipt(id)
+	if receipt == nil {
+		return nil, false
+	}
+	return receipt.content, true
+}
+
+func (s *Server) findReceipt(id string) *receipt {
+	for _, receipt := range s.receipts {
+		if receipt.attachment.ID == id {
+			return receipt
+		}
+	}
+	return nil
+}
+
+func (s *Server) uploadReceipt(w http.ResponseWriter, r *http.Request, body []byte) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		writeError(w, http.StatusBadRequest, "expected a multipart/form-data body")
+		return
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart body: %v", err))
+		return
+	}
+	defer form.RemoveAll()
+
+	transactionID := ""
+	if values := form.Value["transactionId"]; len(values) > 0 {
+		transactionID = values[0]
+	}
+	transaction := s.findTransaction(transactionID)
+	if transaction == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	files := form.File["file"]
+	if len(files) != 1 {
+		writeError(w, http.StatusBadRequest, "expected a single file part")
+		return
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stored := &receipt{
+		attachment: extend.ReceiptAttachment{
+			ID:            s.newID("ra"),
+			TransactionID: transactionID,
+			ContentType:   files[0].Header.Get("Content-Type"),
+			CreatedAt:     s.timestamp(),
+		},
+		content: content,
+	}
+	s.receipts = append(s.receipts, stored)
+	transaction.ReceiptAttachmentIDs = append(transaction.ReceiptAttachmentIDs, stored.attachment.ID)
+
+	writeJSON(w, http.StatusOK, extend.ReceiptAttachmentResponse{ReceiptAttachment: stored.attachment})
+}
+
+func (s *Server) listReceipts(w http.ResponseWriter, transactionID string) {
+	if s.findTransaction(transactionID) == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	attachments := []extend.ReceiptAttachment{}
+	for _, receipt := range s.receipts {
+		if receipt.attachment.TransactionID == transactionID {
+			attachments = append(attachments, receipt.attachment)
+		}
+	}
+	writeJSON(w, http.StatusOK, extend.ListReceiptAttachmentsResponse{ReceiptAttachments: attachments})
+}
+
+func (s *Server) downloadReceipt(w http.ResponseWriter, id string) {
+	receipt := s.findReceipt(id)
+	if receipt == nil {
+		writeError(w, http.StatusNotFound, "Receipt attachment not found")
+		return
+	}
+	w.Header().Set("Content-Type", receipt.attachment.ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(receipt.content)
+}
+
+func (s *Server) deleteReceipt(w http.ResponseWriter, id string) {
+	stored := s.findReceipt(id)
+	if stored == nil {
+		writeError(w, http.StatusNotFound, "Receipt attachment not found")
+		return
+	}
+
+	s.receipts = slices.DeleteFunc(s.receipts, func(r *receipt) bool { return r == stored })
+	if transaction := s.findTransaction(stored.attachment.TransactionID); transaction != nil {
+		transaction.ReceiptAttachmentIDs = slices.DeleteFunc(transaction.ReceiptAttachmentIDs, func(id string) bool { return id == stored.attachment.ID })
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
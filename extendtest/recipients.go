@@ -0,0 +1,180 @@
+package extendtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"local/extend"
+)
+
+// RejectRecipient makes the server refuse email as the recipient of cards for
+// the given reason, like an address Extend can't deliver to
+func (s *Server) RejectRecipient(email, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[strings.ToLower(email)] = reason
+}
+
+// InvitesSent returns how many times the invitation of a card was resent
+func (s *Server) InvitesSent(cardID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.invitesSent[cardID]
+}
+
+func (s *Server) rejection(email string) string {
+	return s.rejected[strings.ToLower(email)]
+}
+
+func (s *Server) findUserByEmail(email string) *extend.User {
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+// recipient returns the recipient of a card sent to email, with its ID when
+// the email has an account
+func (s *Server) recipient(email string) (string, extend.User) {
+	if user := s.findUserByEmail(email); user != nil {
+		return user.ID, *user
+	}
+	return "", extend.User{Email: email}
+}
+
+func (s *Server) validateRecipient(w http.ResponseWriter, email string) {
+	if _, err := mail.ParseAddress(email); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid email", extend.APIErrorDetail{Field: "email", Error: "must be an email", InvalidValue: email})
+		return
+	}
+
+	validation := extend.RecipientValidation{Email: email, Status: extend.RecipientStatusNew}
+	if user := s.findUserByEmail(email); user != nil {
+		validation.Status = extend.RecipientStatusActive
+		if s.invited[user.ID] {
+			validation.Status = extend.RecipientStatusInvited
+		}
+		validation.User = user
+	}
+	if reason := s.rejection(email); reason != "" {
+		validation = extend.RecipientValidation{Email: email, Status: extend.RecipientStatusInvalid, Reason: reason}
+	}
+
+	writeJSON(w, http.StatusOK, extend.RecipientValidationResponse{Recipient: validation})
+}
+
+func (s *Server) inviteRecipient(w http.ResponseWriter, body []byte) {
+	var payload extend.InviteRecipientOptions
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if _, err := mail.ParseAddress(payload.Email); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid invitation", extend.APIErrorDetail{Field: "email", Error: "must be an email", InvalidValue: payload.Email})
+		return
+	}
+	if reason := s.rejection(payload.Email); reason != "" {
+		writeError(w, http.StatusBadRequest, "Invalid invitation", extend.APIErrorDetail{Field: "email", Error: reason, InvalidValue: payload.Email})
+		return
+	}
+	if s.findUserByEmail(payload.Email) != nil {
+		writeError(w, http.StatusConflict, "A user with this email already exists")
+		return
+	}
+
+	user := &extend.User{
+		ID:        s.newID("u"),
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Email:     payload.Email,
+	}
+	if current := s.findUser(s.currentUser); current != nil {
+		user.OrganizationID = current.OrganizationID
+		user.Organization.ID = current.OrganizationID
+	}
+	s.users = append(s.users, user)
+	s.invited[user.ID] = true
+
+	writeJSON(w, http.StatusOK, extend.UserResponse{User: *user})
+}
+
+// activeCard returns a card whose people can be changed, writing an error
+// otherwise
+func (s *Server) activeCard(w http.ResponseWriter, id string) *extend.VirtualCard {
+	card := s.findCard(id)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Virtual card not found")
+		return nil
+	}
+	if card.Status != extend.VirtualCardStatusActive {
+		writeError(w, http.StatusConflict, "Virtual card is not active")
+		return nil
+	}
+	return card
+}
+
+func (s *Server) resendVirtualCardInvite(w http.ResponseWriter, id string) {
+	card := s.activeCard(w, id)
+	if card == nil {
+		return
+	}
+	if card.Recipient.Email == "" {
+		writeError(w, http.StatusBadRequest, "Virtual card has no recipient")
+		return
+	}
+
+	s.invitesSent[card.ID]++
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) reassignVirtualCard(w http.ResponseWriter, id string, body []byte) {
+	card := s.activeCard(w, id)
+	if card == nil {
+		return
+	}
+
+	var payload extend.ReassignVirtualCardOptions
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if payload == (extend.ReassignVirtualCardOptions{}) {
+		writeError(w, http.StatusBadRequest, "Invalid reassignment", extend.APIErrorDetail{Field: "recipient", Error: "recipient or cardholderId must not be empty"})
+		return
+	}
+
+	var details []extend.APIErrorDetail
+	if payload.Recipient != "" {
+		if _, err := mail.ParseAddress(payload.Recipient); err != nil {
+			details = append(details, extend.APIErrorDetail{Field: "recipient", Error: "must be an email", InvalidValue: payload.Recipient})
+		} else if reason := s.rejection(payload.Recipient); reason != "" {
+			details = append(details, extend.APIErrorDetail{Field: "recipient", Error: reason, InvalidValue: payload.Recipient})
+		}
+	}
+	var cardholder *extend.User
+	if payload.CardholderID != "" {
+		if cardholder = s.findUser(payload.CardholderID); cardholder == nil {
+			details = append(details, extend.APIErrorDetail{Field: "cardholderId", Error: "unknown user", InvalidValue: payload.CardholderID})
+		}
+	}
+	if len(details) > 0 {
+		writeError(w, http.StatusBadRequest, "Invalid reassignment", details...)
+		return
+	}
+
+	if payload.Recipient != "" {
+		card.RecipientID, card.Recipient = s.recipient(payload.Recipient)
+	}
+	if cardholder != nil {
+		card.CardholderID = cardholder.ID
+		card.Cardholder = *cardholder
+	}
+	card.UpdatedAt = s.timestamp()
+
+	writeJSON(w, http.StatusOK, extend.VirtualCardResponse{VirtualCard: *card})
+}
@@ -0,0 +1,411 @@
+// Package extendtest runs an in-memory fake of the Extend API for tests.
+//
+//	srv := extendtest.NewServer()
+//	defer srv.Close()
+//
+//	client := srv.Client()
+//	card, err := client.CreateVirtualCard(ctx, options)
+package extendtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"local/extend"
+)
+
+// DefaultToken is the bearer token accepted by a new server
+const DefaultToken = "extendtest-token"
+
+// Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Fault makes the server fail matching requests instead of handling them
+type Fault struct {
+	// Method matches any method when empty
+	Method string
+
+	// Path matches request paths starting with it, any path when empty
+	Path string
+
+	StatusCode int
+	Header     http.Header
+
+	// Body is sent as is, an Extend error with the status text is sent
+	// when empty
+	Body string
+
+	// Times is the number of requests to fail, every matching request fails
+	// when zero
+	Times int
+
+	// Handled makes the server handle the request before failing it, like
+	// a response lost after Extend processed the request
+	Handled bool
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	apiVersion string
+	tokens     map[string]bool
+	validToken func(token string) bool
+	requests   []Request
+	faults     []*Fault
+	now        func() time.Time
+	nextID     int
+
+	cards        []*extend.VirtualCard
+	numbers      map[string]cardNumber
+	uploads      map[string]*extend.BulkVirtualCardUpload
+	transactions []*extend.Transaction
+	receipts     []*receipt
+	creditCards  []*extend.CreditCard
+	users        []*extend.User
+	currentUser  string
+	mccGroups    []extend.MCCGroup
+	cardRequests []*extend.VirtualCardRequest
+	invited      map[string]bool
+	rejected     map[string]string
+	invitesSent  map[string]int
+}
+
+// NewServer starts a fake Extend API accepting DefaultToken. Close it when
+// done.
+func NewServer() *Server {
+	s := &Server{
+		apiVersion:  extend.DefaultAPIVersion,
+		tokens:      map[string]bool{DefaultToken: true},
+		now:         time.Now,
+		uploads:     make(map[string]*extend.BulkVirtualCardUpload),
+		numbers:     make(map[string]cardNumber),
+		invited:     make(map[string]bool),
+		rejected:    make(map[string]string),
+		invitesSent: make(map[string]int),
+		mccGroups:   slices.Clone(DefaultMCCGroups),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client talking to the server with DefaultToken. options
+// are applied after the ones pointing the client at the server.
+func (s *Server) Client(options ...extend.Option) *extend.Client {
+	return extend.New(Token(DefaultToken), append([]extend.Option{
+		extend.WithBaseURL(s.URL),
+		extend.WithHTTPClient(s.Server.Client()),
+		extend.WithRetryPolicy(extend.RetryPolicy{MaxAttempts: 3}),
+		extend.WithRateLimiter(nil),
+	}, options...)...)
+}
+
+// SetAPIVersion changes the API version the server requires in the Accept
+// header
+func (s *Server) SetAPIVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiVersion = version
+}
+
+// AddToken makes the server accept another bearer token
+func (s *Server) AddToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = true
+}
+
+// SetTokenValidator makes the server also accept bearer tokens for which
+// valid returns true, e.g. cognitotest.Server.ValidAccessToken
+func (s *Server) SetTokenValidator(valid func(token string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validToken = valid
+}
+
+// RevokeToken makes the server answer 401 to requests using token
+func (s *Server) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+}
+
+// SetClock replaces the clock used for timestamps
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// InjectFault makes the server fail requests matching fault, faults are
+// checked in the order they were injected
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the requests received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unable to read body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	fault := s.takeFault(r)
+	if fault != nil && !fault.Handled {
+		writeFault(w, fault)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !(s.tokens[token] || s.validToken != nil && s.validToken(token)) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if accept := "application/vnd.paywithextend.v" + s.apiVersion + "+json"; r.Header.Get("Accept") != accept {
+		writeError(w, http.StatusNotAcceptable, fmt.Sprintf("unsupported Accept header %q, expected %q", r.Header.Get("Accept"), accept))
+		return
+	}
+
+	if fault != nil {
+		s.route(httptest.NewRecorder(), r, body)
+		writeFault(w, fault)
+		return
+	}
+
+	s.route(w, r, body)
+}
+
+// takeFault returns the first fault matching r, counting it as used
+func (s *Server) takeFault(r *http.Request) *Fault {
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+func writeFault(w http.ResponseWriter, fault *Fault) {
+	for key, values := range fault.Header {
+		w.Header()[key] = values
+	}
+	if fault.Body == "" {
+		writeError(w, fault.StatusCode, http.StatusText(fault.StatusCode))
+		return
+	}
+	w.WriteHeader(fault.StatusCode)
+	io.WriteString(w, fault.Body)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, body []byte) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case match(segments, "virtualcards"):
+		switch r.Method {
+		case http.MethodGet:
+			s.listVirtualCards(w, r)
+			return
+		case http.MethodPost:
+			s.createVirtualCard(w, body)
+			return
+		}
+	case match(segments, "virtualcards", "*"):
+		switch r.Method {
+		case http.MethodGet:
+			s.getVirtualCard(w, segments[1])
+			return
+		case http.MethodPut:
+			s.updateVirtualCard(w, segments[1], body)
+			return
+		}
+	case match(segments, "virtualcards", "*", "cancel") && r.Method == http.MethodPut:
+		s.transitionVirtualCard(w, segments[1], extend.VirtualCardStatusCancelled)
+		return
+	case match(segments, "virtualcards", "*", "close") && r.Method == http.MethodPut:
+		s.transitionVirtualCard(w, segments[1], extend.VirtualCardStatusClosed)
+		return
+	case match(segments, "virtualcards", "*", "resendinvite") && r.Method == http.MethodPost:
+		s.resendVirtualCardInvite(w, segments[1])
+		return
+	case match(segments, "virtualcards", "*", "reassign") && r.Method == http.MethodPut:
+		s.reassignVirtualCard(w, segments[1], body)
+		return
+	case match(segments, "recipients") && r.Method == http.MethodPost:
+		s.inviteRecipient(w, body)
+		return
+	case match(segments, "recipients", "validate") && r.Method == http.MethodGet:
+		s.validateRecipient(w, r.URL.Query().Get("email"))
+		return
+	case match(segments, "virtualcardrequests"):
+		switch r.Method {
+		case http.MethodGet:
+			s.listVirtualCardRequests(w, r)
+			return
+		case http.MethodPost:
+			s.requestVirtualCard(w, body)
+			return
+		}
+	case match(segments, "virtualcardrequests", "*") && r.Method == http.MethodGet:
+		s.getVirtualCardRequest(w, segments[1])
+		return
+	case match(segments, "virtualcardrequests", "*", "approve") && r.Method == http.MethodPut:
+		s.approveVirtualCardRequest(w, segments[1], body)
+		return
+	case match(segments, "virtualcardrequests", "*", "decline") && r.Method == http.MethodPut:
+		s.declineVirtualCardRequest(w, segments[1], body)
+		return
+	case match(segments, "mccgroups") && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, extend.ListMCCGroupsResponse{MCCGroups: s.mccGroups})
+		return
+	case match(segments, "me") && r.Method == http.MethodGet:
+		s.getCurrentUser(w)
+		return
+	case match(segments, "organizations", "*", "users") && r.Method == http.MethodGet:
+		s.listOrganizationMembers(w, r, segments[1])
+		return
+	case match(segments, "creditcards") && r.Method == http.MethodGet:
+		s.listCreditCards(w, r)
+		return
+	case match(segments, "creditcards", "*") && r.Method == http.MethodGet:
+		s.getCreditCard(w, segments[1])
+		return
+	case match(segments, "creditcards", "*", "bulkvirtualcardpush") && r.Method == http.MethodPost:
+		s.bulkVirtualCardPush(w, r, segments[1], body)
+		return
+	case match(segments, "bulkvirtualcarduploads", "*") && r.Method == http.MethodGet:
+		s.getBulkVirtualCardUpload(w, segments[1])
+		return
+	case match(segments, "transactions") && r.Method == http.MethodGet:
+		s.listTransactions(w, r)
+		return
+	case match(segments, "transactions", "*") && r.Method == http.MethodGet:
+		s.getTransaction(w, segments[1])
+		return
+	case match(segments, "transactions", "*", "receiptattachments") && r.Method == http.MethodGet:
+		s.listReceipts(w, segments[1])
+		return
+	case match(segments, "receiptattachments") && r.Method == http.MethodPost:
+		s.uploadReceipt(w, r, body)
+		return
+	case match(segments, "receiptattachments", "*") && r.Method == http.MethodDelete:
+		s.deleteReceipt(w, segments[1])
+		return
+	case match(segments, "receiptattachments", "*", "content") && r.Method == http.MethodGet:
+		s.downloadReceipt(w, segments[1])
+		return
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+}
+
+// match reports whether path segments match a pattern where "*" matches any
+// single segment
+func match(segments []string, pattern ...string) bool {
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s_%06d", prefix, s.nextID)
+}
+
+func (s *Server) timestamp() *extend.Time {
+	return &extend.Time{Time: s.now().UTC().Truncate(time.Millisecond)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string, details ...extend.APIErrorDetail) {
+	writeJSON(w, status, extend.APIError{
+		Message: message,
+		Details: details,
+	})
+}
+
+// Token is an extend.Authenticator always returning the same access token
+type Token string
+
+var (
+	_ extend.Authenticator = Token("")
+)
+
+func (t Token) GetAccessToken(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+func (t Token) Expiry() time.Time {
+	return time.Now().Add(time.Hour)
+}
+
+func (t Token) Refresh(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+func (t Token) Invalidate(accessToken string) {}
@@ -0,0 +1,165 @@
+package extendtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func seedCard(server *extendtest.Server, name string) extend.VirtualCard {
+	return server.SeedCard(extend.VirtualCard{
+		DisplayName:  name,
+		CreditCardID: "cc_1",
+		BalanceCents: 1000,
+		ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+	})
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault extendtest.Fault
+		// failures is the number of failed updates out of two
+		failures int
+		// renamed reports whether the card was renamed by the first update
+		renamed bool
+	}{
+		{"once", extendtest.Fault{StatusCode: http.StatusInternalServerError, Times: 1}, 1, false},
+		{"always", extendtest.Fault{StatusCode: http.StatusInternalServerError}, 2, false},
+		{"other method", extendtest.Fault{Method: http.MethodPost, StatusCode: http.StatusInternalServerError}, 0, true},
+		{"other path", extendtest.Fault{Path: "/transactions", StatusCode: http.StatusInternalServerError}, 0, true},
+		{"handled", extendtest.Fault{Method: http.MethodPut, Path: "/virtualcards", StatusCode: http.StatusBadGateway, Handled: true, Times: 1}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			card := seedCard(server, "Travel")
+			server.InjectFault(test.fault)
+			client := server.Client(extend.WithRetryPolicy(extend.RetryPolicy{MaxAttempts: 1}))
+			ctx := context.Background()
+
+			failures := 0
+			for i, name := range []string{"Trips", "Trips"} {
+				_, err := client.UpdateVirtualCard(ctx, card.ID, extend.UpdateVirtualCardOptions{DisplayName: extend.Ptr(name)})
+				var apiErr *extend.APIError
+				switch {
+				case err == nil:
+				case errors.As(err, &apiErr) && apiErr.StatusCode == test.fault.StatusCode:
+					failures++
+				default:
+					t.Fatalf("update %d: %v", i, err)
+				}
+				if i == 0 {
+					stored, _ := server.Card(card.ID)
+					if renamed := stored.DisplayName == "Trips"; renamed != test.renamed {
+						t.Errorf("got renamed %t, want %t", renamed, test.renamed)
+					}
+				}
+			}
+			if failures != test.failures {
+				t.Errorf("got %d failures, want %d", failures, test.failures)
+			}
+
+			server.ClearFaults()
+			if _, err := client.GetVirtualCard(ctx, card.ID); err != nil {
+				t.Errorf("cleared faults still apply: %v", err)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		cards int
+		count int
+	}{
+		{0, 2},
+		{1, 2},
+		{4, 2},
+		{5, 2},
+		{5, 20},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			for i := 0; i < test.cards; i++ {
+				seedCard(server, "Card")
+			}
+
+			seen := map[string]bool{}
+			list := server.Client().ListVirtualCards(&extend.ListVirtualCardsOptions{
+				PaginationOptions: extend.PaginationOptions{Count: test.count},
+			})
+			for list.Next() {
+				page, err := list.Get(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if items := page.Items(); len(items) > test.count {
+					t.Errorf("got a page of %d cards, want at most %d", len(items), test.count)
+				}
+				for _, card := range page.Items() {
+					if seen[card.ID] {
+						t.Errorf("card %s listed twice", card.ID)
+					}
+					seen[card.ID] = true
+				}
+			}
+			if len(seen) != test.cards {
+				t.Errorf("listed %d cards, want %d", len(seen), test.cards)
+			}
+		})
+	}
+}
+
+func TestRequestChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(server *extendtest.Server)
+		token string
+		// status is the status of the error returned, 0 for no error
+		status int
+	}{
+		{"default token", func(*extendtest.Server) {}, extendtest.DefaultToken, 0},
+		{"unknown token", func(*extendtest.Server) {}, "other", http.StatusUnauthorized},
+		{"added token", func(server *extendtest.Server) { server.AddToken("other") }, "other", 0},
+		{"revoked token", func(server *extendtest.Server) { server.RevokeToken(extendtest.DefaultToken) }, extendtest.DefaultToken, http.StatusUnauthorized},
+		{"validated token", func(server *extendtest.Server) {
+			server.SetTokenValidator(func(token string) bool { return token == "other" })
+		}, "other", 0},
+		{"other API version", func(server *extendtest.Server) { server.SetAPIVersion("2.0") }, extendtest.DefaultToken, http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			card := seedCard(server, "Travel")
+			test.setup(server)
+
+			client := extend.New(extendtest.Token(test.token),
+				extend.WithBaseURL(server.URL),
+				extend.WithHTTPClient(server.Server.Client()),
+				extend.WithRateLimiter(nil),
+			)
+			_, err := client.GetVirtualCard(context.Background(), card.ID)
+
+			var apiErr *extend.APIError
+			switch {
+			case test.status == 0 && err != nil:
+				t.Errorf("got error %v", err)
+			case test.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != test.status):
+				t.Errorf("got error %v, want status %d", err, test.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package extendtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"local/extend"
+)
+
+// SeedTransaction adds a transaction to the server and returns it as stored.
+// Missing fields are filled from its virtual card when it exists, and with
+// an ID, the PENDING status, the DEBIT type and the current time. Without a
+// status, transactions authorized before the card is valid or at merchant
+// categories its MCC controls forbid are DECLINED.
+func (s *Server) SeedTransaction(transaction extend.Transaction) extend.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if transaction.ID == "" {
+		transaction.ID = s.newID("txn")
+	}
+	if transaction.Type == "" {
+		transaction.Type = extend.TransactionTypeDebit
+	}
+	if transaction.AuthedAt == nil {
+		transaction.AuthedAt = s.timestamp()
+	}
+	if card := s.findCard(transaction.VirtualCardID); card != nil {
+		switch {
+		case transaction.Status != "":
+		case card.ValidFrom != nil && transaction.AuthedAt.Before(card.ValidFrom.Time):
+			transaction.Status = extend.TransactionStatusDeclined
+			transaction.DeclineReason = "CARD_NOT_YET_ACTIVE"
+		case card.MCCControl != nil && !card.MCCControl.Allows(transaction.MCC, s.mccGroups):
+			transaction.Status = extend.TransactionStatusDeclined
+			transaction.DeclineReason = "MCC_BLOCKED"
+		}
+		if transaction.VirtualCardDisplayName == "" {
+			transaction.VirtualCardDisplayName = card.DisplayName
+		}
+		if transaction.VirtualCardLast4 == "" {
+			transaction.VirtualCardLast4 = card.Last4
+		}
+		if transaction.CreditCardID == "" {
+			transaction.CreditCardID = card.CreditCardID
+		}
+	}
+	if transaction.Status == "" {
+		transaction.Status = extend.TransactionStatusPending
+	}
+	if transaction.AuthBillingCurrency == "" {
+		transaction.AuthBillingCurrency = string(extend.CurrencyUSD)
+	}
+	if transaction.UpdatedAt == nil {
+		transaction.UpdatedAt = transaction.AuthedAt
+	}
+
+	s.transactions = append(s.transactions, &transaction)
+	return transaction
+}
+
+func (s *Server) findTransaction(id string) *extend.Transaction {
+	for _, transaction := range s.transactions {
+		if transaction.ID == id {
+			return transaction
+		}
+	}
+	return nil
+}
+
+func (s *Server) getTransaction(w http.ResponseWriter, id string) {
+	transaction := s.findTransaction(id)
+	if transaction == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, extend.TransactionResponse{Transaction: *transaction})
+}
+
+// transactionFilter matches the transactions selected by the query of a
+// list request
+func transactionFilter(w http.ResponseWriter, query map[string][]string) (func(extend.Transaction) bool, bool) {
+	get := func(key string) string {
+		if values := query[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	var filters []func(extend.Transaction) bool
+	if id := get("virtualCardId"); id != "" {
+		filters = append(filters, func(t extend.Transaction) bool { return t.VirtualCardID == id })
+	}
+	for _, bound := range []struct {
+		key    string
+		before bool
+	}{{"since", false}, {"until", true}} {
+		value := get(bound.key)
+		if value == "" {
+			continue
+		}
+		limit, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s", bound.key), extend.APIErrorDetail{Field: bound.key, Error: "must be a RFC 3339 time", InvalidValue: value})
+			return nil, false
+		}
+		before := bound.before
+		filters = append(filters, func(t extend.Transaction) bool {
+			if before {
+				return !t.AuthedAt.After(limit)
+			}
+			return !t.AuthedAt.Before(limit)
+		})
+	}
+	if value := get("statuses"); value != "" {
+		statuses := map[extend.TransactionStatus]bool{}
+		for _, status := range strings.Split(value, ",") {
+			statuses[extend.TransactionStatus(status)] = true
+		}
+		filters = append(filters, func(t extend.Transaction) bool { return statuses[t.Status] })
+	}
+	for _, bound := range []struct {
+		key string
+		max bool
+	}{{"minAmountCents", false}, {"maxAmountCents", true}} {
+		value := get(bound.key)
+		if value == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s", bound.key), extend.APIErrorDetail{Field: bound.key, Error: "must be an integer", InvalidValue: value})
+			return nil, false
+		}
+		max := bound.max
+		filters = append(filters, func(t extend.Transaction) bool {
+			if max {
+				return t.AmountCents() <= limit
+			}
+			return t.AmountCents() >= limit
+		})
+	}
+	if search := strings.ToLower(get("search")); search != "" {
+		filters = append(filters, func(t extend.Transaction) bool {
+			return strings.Contains(strings.ToLower(t.MerchantName), search)
+		})
+	}
+
+	return func(t extend.Transaction) bool {
+		for _, filter := range filters {
+			if !filter(t) {
+				return false
+			}
+		}
+		return true
+	}, true
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	matches, ok := transactionFilter(w, query)
+	if !ok {
+		return
+	}
+
+	var transactions []extend.Transaction
+	for _, transaction := range s.transactions {
+		if matches(*transaction) {
+			transactions = append(transactions, *transaction)
+		}
+	}
+
+	less := transactionSortFields[query.Get("sortField")]
+	if less == nil {
+		less = transactionSortFields["authedAt"]
+	}
+	desc := query.Get("sortDirection") == string(extend.SortDirectionDesc)
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if desc {
+			return less(transactions[j], transactions[i])
+		}
+		return less(transactions[i], transactions[j])
+	})
+
+	page, pagination, ok := paginate(w, query, len(transactions))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, extend.ListTransactionsResponse{
+		PaginationResponse: extend.PaginationResponse{PaginationData: pagination},
+		Transactions:       transactions[page[0]:page[1]],
+	})
+}
+
+var transactionSortFields = map[string]func(a, b extend.Transaction) bool{
+	"authedAt": func(a, b extend.Transaction) bool {
+		return a.AuthedAt.Before(b.AuthedAt.Time)
+	},
+	"updatedAt": func(a, b extend.Transaction) bool {
+		return a.UpdatedAt.Before(b.UpdatedAt.Time)
+	},
+	"amountCents": func(a, b extend.Transaction) bool {
+		return a.AmountCents() < b.AmountCents()
+	},
+	"merchantName": func(a, b extend.Transaction) bool {
+		return a.MerchantName < b.MerchantName
+	},
+}
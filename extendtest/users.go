@@ -0,0 +1,89 @@
+package extendtest
+
+import (
+	"net/http"
+	"strings"
+
+	"local/extend"
+)
+
+// SeedUser adds a user to the server and returns it as stored. Missing IDs
+// are generated. The first user added is the one the client is logged in as
+// until SetCurrentUser is called.
+func (s *Server) SeedUser(user extend.User) extend.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID == "" {
+		user.ID = s.newID("u")
+	}
+	if user.OrganizationID == "" {
+		user.OrganizationID = user.Organization.ID
+	}
+	if user.Organization.ID == "" {
+		user.Organization.ID = user.OrganizationID
+	}
+	if user.OrganizationRole == "" {
+		user.OrganizationRole = user.Organization.Role
+	}
+	if user.Organization.Role == "" {
+		user.Organization.Role = user.OrganizationRole
+	}
+	if s.currentUser == "" {
+		s.currentUser = user.ID
+	}
+
+	s.users = append(s.users, &user)
+	return user
+}
+
+// SetCurrentUser changes the user the client is logged in as
+func (s *Server) SetCurrentUser(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUser = id
+}
+
+func (s *Server) findUser(id string) *extend.User {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *Server) getCurrentUser(w http.ResponseWriter) {
+	user := s.findUser(s.currentUser)
+	if user == nil {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, extend.UserResponse{User: *user})
+}
+
+func (s *Server) listOrganizationMembers(w http.ResponseWriter, r *http.Request, organizationID string) {
+	query := r.URL.Query()
+	search := strings.ToLower(query.Get("search"))
+
+	users := []extend.User{}
+	for _, user := range s.users {
+		if user.OrganizationID != organizationID {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(user.Name()), search) && !strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+		users = append(users, *user)
+	}
+
+	page, pagination, ok := paginate(w, query, len(users))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, extend.ListOrganizationMembersResponse{
+		PaginationResponse: extend.PaginationResponse{PaginationData: pagination},
+		Users:              users[page[0]:page[1]],
+	})
+}
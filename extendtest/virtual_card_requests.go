@@ -0,0 +1,217 @@
+package extendtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"local/extend"
+)
+
+// SeedVirtualCardRequest adds a card request to the server and returns it as
+// stored. Missing fields are filled with an ID, the PENDING status, the USD
+// currency and timestamps.
+func (s *Server) SeedVirtualCardRequest(request extend.VirtualCardRequest) extend.VirtualCardRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.addVirtualCardRequest(request)
+}
+
+func (s *Server) addVirtualCardRequest(request extend.VirtualCardRequest) *extend.VirtualCardRequest {
+	if request.ID == "" {
+		request.ID = s.newID("vcr")
+	}
+	if request.Status == "" {
+		request.Status = extend.VirtualCardRequestStatusPending
+	}
+	if request.Currency == "" {
+		request.Currency = string(extend.CurrencyUSD)
+	}
+	if request.RequesterID == "" {
+		if user := s.findUser(s.currentUser); user != nil {
+			request.RequesterID = user.ID
+			request.Requester = *user
+		}
+	}
+	if request.CreatedAt == nil {
+		request.CreatedAt = s.timestamp()
+	}
+	if request.UpdatedAt == nil {
+		request.UpdatedAt = request.CreatedAt
+	}
+
+	s.cardRequests = append(s.cardRequests, &request)
+	return &request
+}
+
+func (s *Server) findVirtualCardRequest(id string) *extend.VirtualCardRequest {
+	for _, request := range s.cardRequests {
+		if request.ID == id {
+			return request
+		}
+	}
+	return nil
+}
+
+func (s *Server) requestVirtualCard(w http.ResponseWriter, body []byte) {
+	var payload virtualCardPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if details := s.validatePayload(payload); len(details) > 0 {
+		writeError(w, http.StatusBadRequest, "Invalid virtual card request", details...)
+		return
+	}
+
+	request := s.addVirtualCardRequest(extend.VirtualCardRequest{
+		CreditCardID: payload.CreditCardID,
+		DisplayName:  payload.DisplayName,
+		BalanceCents: payload.BalanceCents,
+		Currency:     payload.Currency,
+		Notes:        payload.Notes,
+		ValidFrom:    parseDate(payload.ValidFrom, ""),
+		ValidTo:      parseDate(payload.ValidTo, ""),
+	})
+	writeJSON(w, http.StatusOK, extend.VirtualCardRequestResponse{VirtualCardRequest: *request})
+}
+
+func (s *Server) getVirtualCardRequest(w http.ResponseWriter, id string) {
+	request := s.findVirtualCardRequest(id)
+	if request == nil {
+		writeError(w, http.StatusNotFound, "Virtual card request not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, extend.VirtualCardRequestResponse{VirtualCardRequest: *request})
+}
+
+func (s *Server) listVirtualCardRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	statuses := map[extend.VirtualCardRequestStatus]bool{}
+	for _, status := range strings.Split(query.Get("statuses"), ",") {
+		if status != "" {
+			statuses[extend.VirtualCardRequestStatus(status)] = true
+		}
+	}
+	creditCardID := query.Get("creditCardId")
+
+	requests := []extend.VirtualCardRequest{}
+	for _, request := range s.cardRequests {
+		if len(statuses) > 0 && !statuses[request.Status] {
+			continue
+		}
+		if creditCardID != "" && request.CreditCardID != creditCardID {
+			continue
+		}
+		requests = append(requests, *request)
+	}
+
+	page, pagination, ok := paginate(w, query, len(requests))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, extend.ListVirtualCardRequestsResponse{
+		PaginationResponse:  extend.PaginationResponse{PaginationData: pagination},
+		VirtualCardRequests: requests[page[0]:page[1]],
+	})
+}
+
+// pendingVirtualCardRequest returns a request that can still be reviewed,
+// writing an error otherwise
+func (s *Server) pendingVirtualCardRequest(w http.ResponseWriter, id string) *extend.VirtualCardRequest {
+	request := s.findVirtualCardRequest(id)
+	if request == nil {
+		writeError(w, http.StatusNotFound, "Virtual card request not found")
+		return nil
+	}
+	if request.Status != extend.VirtualCardRequestStatusPending {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Virtual card request is %s", request.Status))
+		return nil
+	}
+	return request
+}
+
+func (s *Server) approveVirtualCardRequest(w http.ResponseWriter, id string, body []byte) {
+	request := s.pendingVirtualCardRequest(w, id)
+	if request == nil {
+		return
+	}
+
+	var payload struct {
+		BalanceCents *int   `json:"balanceCents"`
+		ValidFrom    string `json:"validFrom"`
+		ValidTo      string `json:"validTo"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	card := extend.VirtualCard{
+		DisplayName:  request.DisplayName,
+		Notes:        request.Notes,
+		BalanceCents: request.BalanceCents,
+		Currency:     request.Currency,
+		CreditCardID: request.CreditCardID,
+		CardholderID: request.RequesterID,
+		Cardholder:   request.Requester,
+		ValidFrom:    request.ValidFrom,
+		ValidTo:      request.ValidTo,
+	}
+	if payload.BalanceCents != nil {
+		if *payload.BalanceCents <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid approval", extend.APIErrorDetail{Field: "balanceCents", Error: "must be positive"})
+			return
+		}
+		card.BalanceCents = *payload.BalanceCents
+	}
+	if payload.ValidFrom != "" {
+		card.ValidFrom = parseDate(payload.ValidFrom, "")
+	}
+	if payload.ValidTo != "" {
+		card.ValidTo = parseDate(payload.ValidTo, "")
+	}
+
+	created := s.addCard(card)
+	request.Status = extend.VirtualCardRequestStatusApproved
+	request.VirtualCardID = created.ID
+	request.UpdatedAt = s.timestamp()
+	if user := s.findUser(s.currentUser); user != nil {
+		request.ReviewerID = user.ID
+		request.Reviewer = user
+	}
+
+	writeJSON(w, http.StatusOK, extend.VirtualCardResponse{VirtualCard: *created})
+}
+
+func (s *Server) declineVirtualCardRequest(w http.ResponseWriter, id string, body []byte) {
+	request := s.pendingVirtualCardRequest(w, id)
+	if request == nil {
+		return
+	}
+
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if payload.Reason == "" {
+		writeError(w, http.StatusBadRequest, "Invalid decline", extend.APIErrorDetail{Field: "reason", Error: "must not be empty"})
+		return
+	}
+
+	request.Status = extend.VirtualCardRequestStatusDeclined
+	request.DeclineReason = payload.Reason
+	request.UpdatedAt = s.timestamp()
+	if user := s.findUser(s.currentUser); user != nil {
+		request.ReviewerID = user.ID
+		request.Reviewer = user
+	}
+
+	writeJSON(w, http.StatusOK, extend.VirtualCardRequestResponse{VirtualCardRequest: *request})
+}
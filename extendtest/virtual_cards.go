@@ -0,0 +1,423 @@
+package extendtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"local/extend"
+)
+
+// SeedCard adds a card to the server and returns it as stored. Missing
+// fields are filled like for a card created through the API: an ID, the
+// ACTIVE status and timestamps. A card number ending with Last4, when set,
+// and a security code are generated.
+func (s *Server) SeedCard(card extend.VirtualCard) extend.VirtualCard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.addCard(card)
+}
+
+// Card returns a card stored by the server
+func (s *Server) Card(id string) (extend.VirtualCard, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card := s.findCard(id)
+	if card == nil {
+		return extend.VirtualCard{}, false
+	}
+	return *card, true
+}
+
+// Cards returns all the cards stored by the server in creation order
+func (s *Server) Cards() []extend.VirtualCard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cards := make([]extend.VirtualCard, len(s.cards))
+	for i, card := range s.cards {
+		cards[i] = *card
+	}
+	return cards
+}
+
+type cardNumber struct {
+	number       string
+	securityCode string
+}
+
+// CardNumber returns the number and security code generated for a card
+func (s *Server) CardNumber(id string) (number, securityCode string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.numbers[id]
+	return n.number, n.securityCode, ok
+}
+
+func (s *Server) addCard(card extend.VirtualCard) *extend.VirtualCard {
+	if card.ID == "" {
+		card.ID = s.newID("vc")
+	}
+	if card.Status == "" {
+		card.Status = extend.VirtualCardStatusActive
+	}
+	if card.CardType == "" {
+		card.CardType = string(extend.VirtualCardTypeStandard)
+	}
+	if card.Currency == "" {
+		card.Currency = string(extend.CurrencyUSD)
+	}
+	if card.LimitCents == 0 {
+		card.LimitCents = card.BalanceCents
+	}
+
+	number := fmt.Sprintf("4%015d", s.nextID)
+	if card.Last4 != "" {
+		number = number[:len(number)-len(card.Last4)] + card.Last4
+	}
+	card.Last4 = number[len(number)-4:]
+	s.numbers[card.ID] = cardNumber{number: number, securityCode: fmt.Sprintf("%03d", s.nextID%1000)}
+
+	if card.CreatedAt == nil {
+		card.CreatedAt = s.timestamp()
+	}
+	if card.UpdatedAt == nil {
+		card.UpdatedAt = card.CreatedAt
+	}
+	if card.ValidFrom == nil {
+		card.ValidFrom = card.CreatedAt
+	}
+	if card.Expires == nil {
+		expires := *card.CreatedAt
+		expires.Time = expires.AddDate(3, 0, 0)
+		card.Expires = &expires
+	}
+
+	s.cards = append(s.cards, &card)
+	return &card
+}
+
+func (s *Server) findCard(id string) *extend.VirtualCard {
+	for _, card := range s.cards {
+		if card.ID == id {
+			return card
+		}
+	}
+	return nil
+}
+
+// virtualCardPayload is the body of create and update requests
+type virtualCardPayload struct {
+	CreditCardID       string `json:"creditCardId"`
+	DisplayName        string `json:"displayName"`
+	BalanceCents       int    `json:"balanceCents"`
+	Currency           string `json:"currency"`
+	Notes              string `json:"notes"`
+	ValidFrom          string `json:"validFrom"`
+	ValidTo            string `json:"validTo"`
+	Timezone           string `json:"timezone"`
+	Recipient          string `json:"recipient"`
+	Recurs             bool   `json:"recurs"`
+	ReceiptRulesExempt bool   `json:"receiptRulesExempt"`
+
+	Recurrence *extend.Recurrence `json:"recurrence"`
+	MCCControl *extend.MCCControl `json:"mccControl"`
+}
+
+func (s *Server) validatePayload(p virtualCardPayload) []extend.APIErrorDetail {
+	var details []extend.APIErrorDetail
+	if p.CreditCardID == "" {
+		details = append(details, extend.APIErrorDetail{Field: "creditCardId", Error: "must not be empty"})
+	}
+	if p.DisplayName == "" {
+		details = append(details, extend.APIErrorDetail{Field: "displayName", Error: "must not be empty"})
+	}
+	if p.BalanceCents <= 0 {
+		details = append(details, extend.APIErrorDetail{Field: "balanceCents", Error: "must be positive", InvalidValue: strconv.Itoa(p.BalanceCents)})
+	}
+	for field, value := range map[string]string{"validFrom": p.ValidFrom, "validTo": p.ValidTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			details = append(details, extend.APIErrorDetail{Field: field, Error: "must be a date", InvalidValue: value})
+		}
+	}
+	if p.ValidFrom != "" && p.ValidTo != "" && p.ValidFrom > p.ValidTo {
+		details = append(details, extend.APIErrorDetail{Field: "validFrom", Error: "must not be after validTo", InvalidValue: p.ValidFrom})
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			details = append(details, extend.APIErrorDetail{Field: "timezone", Error: "must be an IANA timezone", InvalidValue: p.Timezone})
+		}
+	}
+	if p.Recurs && p.Recurrence == nil {
+		details = append(details, extend.APIErrorDetail{Field: "recurrence", Error: "must not be empty for a recurring card"})
+	}
+	if p.MCCControl != nil {
+		for _, id := range p.MCCControl.MCCGroups {
+			if s.findMCCGroup(id) == nil {
+				details = append(details, extend.APIErrorDetail{Field: "mccControl.mccGroups", Error: "unknown MCC group", InvalidValue: id})
+			}
+		}
+	}
+	return details
+}
+
+// recurrence returns the recurrence of a card created or updated with
+// payload, keeping the ID of an existing recurrence
+func (s *Server) recurrence(payload virtualCardPayload) *extend.Recurrence {
+	if !payload.Recurs || payload.Recurrence == nil {
+		return nil
+	}
+	recurrence := *payload.Recurrence
+	if recurrence.ID == "" {
+		recurrence.ID = s.newID("rec")
+	}
+	if recurrence.Interval == 0 {
+		recurrence.Interval = 1
+	}
+	return &recurrence
+}
+
+// parseDate returns the start of a date in a timezone, UTC when empty
+func parseDate(value, timezone string) *extend.Time {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return nil
+	}
+	return &extend.Time{Time: date.UTC()}
+}
+
+func (s *Server) createVirtualCard(w http.ResponseWriter, body []byte) {
+	var payload virtualCardPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if details := s.validatePayload(payload); len(details) > 0 {
+		writeError(w, http.StatusBadRequest, "Invalid virtual card", details...)
+		return
+	}
+
+	card := s.addCard(extend.VirtualCard{
+		DisplayName:        payload.DisplayName,
+		Notes:              payload.Notes,
+		BalanceCents:       payload.BalanceCents,
+		Currency:           payload.Currency,
+		CreditCardID:       payload.CreditCardID,
+		ValidFrom:          parseDate(payload.ValidFrom, payload.Timezone),
+		ValidTo:            parseDate(payload.ValidTo, payload.Timezone),
+		Timezone:           payload.Timezone,
+		Recurs:             payload.Recurs,
+		Recurrence:         s.recurrence(payload),
+		MCCControl:         payload.MCCControl,
+		ReceiptRulesExempt: payload.ReceiptRulesExempt,
+	})
+	card.RecipientID, card.Recipient = s.recipient(payload.Recipient)
+
+	// Like Extend, the card number isn't returned on creation
+	writeJSON(w, http.StatusOK, extend.VirtualCardResponse{VirtualCard: *card})
+}
+
+func (s *Server) getVirtualCard(w http.ResponseWriter, id string) {
+	card := s.findCard(id)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Virtual card not found")
+		return
+	}
+
+	// Like Extend, the card number is only returned when getting a card
+	type virtualCardWithNumber struct {
+		extend.VirtualCard
+		Vcn          string `json:"vcn"`
+		SecurityCode string `json:"securityCode"`
+	}
+	number := s.numbers[id]
+	writeJSON(w, http.StatusOK, struct {
+		VirtualCard virtualCardWithNumber `json:"virtualCard"`
+	}{virtualCardWithNumber{*card, number.number, number.securityCode}})
+}
+
+func (s *Server) updateVirtualCard(w http.ResponseWriter, id string, body []byte) {
+	card := s.findCard(id)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Virtual card not found")
+		return
+	}
+	if card.Status != extend.VirtualCardStatusActive {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Virtual card is %s", card.Status))
+		return
+	}
+
+	var payload virtualCardPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if details := s.validatePayload(payload); len(details) > 0 {
+		writeError(w, http.StatusBadRequest, "Invalid virtual card", details...)
+		return
+	}
+
+	card.CreditCardID = payload.CreditCardID
+	card.DisplayName = payload.DisplayName
+	// balanceCents is the new limit, the money spent is kept
+	spent := card.LimitCents - card.BalanceCents
+	card.LimitCents = payload.BalanceCents
+	card.BalanceCents = payload.BalanceCents - spent
+	card.Currency = payload.Currency
+	if payload.Timezone != "" {
+		card.Timezone = payload.Timezone
+	}
+	if payload.ValidFrom != "" {
+		card.ValidFrom = parseDate(payload.ValidFrom, card.Timezone)
+	}
+	card.ValidTo = parseDate(payload.ValidTo, card.Timezone)
+	card.Recurs = payload.Recurs
+	card.Recurrence = s.recurrence(payload)
+	card.MCCControl = payload.MCCControl
+	card.ReceiptRulesExempt = payload.ReceiptRulesExempt
+	card.UpdatedAt = s.timestamp()
+
+	writeJSON(w, http.StatusOK, extend.VirtualCardResponse{VirtualCard: *card})
+}
+
+// transitionVirtualCard cancels or closes a card. Active cards can be
+// cancelled or closed, cancelled cards can only be closed and closed cards
+// are final.
+func (s *Server) transitionVirtualCard(w http.ResponseWriter, id string, status extend.VirtualCardStatus) {
+	card := s.findCard(id)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Virtual card not found")
+		return
+	}
+
+	allowed := card.Status == extend.VirtualCardStatusActive ||
+		(card.Status == extend.VirtualCardStatusCancelled && status == extend.VirtualCardStatusClosed)
+	if !allowed {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot change virtual card from %s to %s", card.Status, status))
+		return
+	}
+
+	card.Status = status
+	card.UpdatedAt = s.timestamp()
+	if card.InactiveSince == nil {
+		card.InactiveSince = card.UpdatedAt
+	}
+
+	writeJSON(w, http.StatusOK, extend.VirtualCardResponse{VirtualCard: *card})
+}
+
+func (s *Server) listVirtualCards(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	statuses := map[extend.VirtualCardStatus]bool{}
+	for _, status := range strings.Split(query.Get("statuses"), ",") {
+		if status != "" {
+			statuses[extend.VirtualCardStatus(status)] = true
+		}
+	}
+
+	person := query.Get("cardholderOrViewer")
+	if person == "me" {
+		person = s.currentUser
+	}
+
+	var cards []extend.VirtualCard
+	for _, card := range s.cards {
+		if len(statuses) > 0 && !statuses[card.Status] {
+			continue
+		}
+		if person != "" && card.CardholderID != person && card.RecipientID != person {
+			continue
+		}
+		cards = append(cards, *card)
+	}
+
+	less := cardSortFields[query.Get("sortField")]
+	if less == nil {
+		less = cardSortFields["createdAt"]
+	}
+	desc := query.Get("sortDirection") == string(extend.SortDirectionDesc)
+	sort.SliceStable(cards, func(i, j int) bool {
+		if desc {
+			return less(cards[j], cards[i])
+		}
+		return less(cards[i], cards[j])
+	})
+
+	page, pagination, ok := paginate(w, query, len(cards))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, extend.ListVirtualCardsResponse{
+		PaginationResponse: extend.PaginationResponse{PaginationData: pagination},
+		VirtualCards:       cards[page[0]:page[1]],
+	})
+}
+
+var cardSortFields = map[string]func(a, b extend.VirtualCard) bool{
+	"createdAt": func(a, b extend.VirtualCard) bool {
+		return a.CreatedAt.Before(b.CreatedAt.Time)
+	},
+	"updatedAt": func(a, b extend.VirtualCard) bool {
+		return a.UpdatedAt.Before(b.UpdatedAt.Time)
+	},
+	"activeClosedUpdatedAt": func(a, b extend.VirtualCard) bool {
+		return a.UpdatedAt.Before(b.UpdatedAt.Time)
+	},
+	"displayName": func(a, b extend.VirtualCard) bool {
+		return a.DisplayName < b.DisplayName
+	},
+	"balanceCents": func(a, b extend.VirtualCard) bool {
+		return a.BalanceCents < b.BalanceCents
+	},
+}
+
+// paginate reads the page and count query parameters and returns the bounds
+// of the requested page within total items
+func paginate(w http.ResponseWriter, query map[string][]string, total int) ([2]int, extend.Pagination, bool) {
+	get := func(key string, fallback int) (int, bool) {
+		values := query[key]
+		if len(values) == 0 || values[0] == "" {
+			return fallback, true
+		}
+		v, err := strconv.Atoi(values[0])
+		if err != nil || v < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s", key), extend.APIErrorDetail{Field: key, Error: "must be a positive integer", InvalidValue: values[0]})
+			return 0, false
+		}
+		return v, true
+	}
+
+	page, ok := get("page", 0)
+	if !ok {
+		return [2]int{}, extend.Pagination{}, false
+	}
+	count, ok := get("count", 20)
+	if !ok {
+		return [2]int{}, extend.Pagination{}, false
+	}
+	if count == 0 {
+		count = 20
+	}
+
+	start := min(page*count, total)
+	end := min(start+count, total)
+
+	return [2]int{start, end}, extend.Pagination{
+		Page:          page,
+		PageItemCount: end - start,
+		TotalItems:    total,
+		NumberOfPages: (total + count - 1) / count,
+	}, true
+}
@@ -0,0 +1,331 @@
+package extend
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("extend: idempotency key reused with different options")
+
+	// ErrCreationInProgress is returned when another process sharing the
+	// journal is creating a card with the same idempotency key and the card
+	// isn't found yet
+	ErrCreationInProgress = errors.New("extend: virtual card creation in progress with the same idempotency key")
+)
+
+const (
+	// markerPrefix starts the marker appended to the notes of created cards,
+	// used to find a card whose creation response was lost
+	markerPrefix = "[idempotency-key:"
+
+	// markerSearchPages and markerSearchPageSize bound the search for a
+	// marked card among the most recently created ones
+	markerSearchPages    = 3
+	markerSearchPageSize = 50
+
+	// markerSearchSkew tolerates clock differences between the client and
+	// Extend when deciding a card is too old to be the one searched for
+	markerSearchSkew = 5 * time.Minute
+
+	// markerSearchTimeout bounds the search after a create failed because
+	// the caller's context was done
+	markerSearchTimeout = 30 * time.Second
+
+	// inFlightTimeout is how long an entry that was started and never ended
+	// is taken as a creation still running elsewhere. Older entries are left
+	// by a process that stopped mid-creation.
+	inFlightTimeout = 5 * time.Minute
+)
+
+// JournalEntry is a card creation recorded by an IdempotencyJournal
+type JournalEntry struct {
+	Key string `json:"key"`
+
+	// Fingerprint identifies the options the card was created with
+	Fingerprint string    `json:"fingerprint"`
+	StartedAt   time.Time `json:"startedAt"`
+
+	// EndedAt is set when the creation failed without a definite answer,
+	// an entry without it or CardID is still being created
+	EndedAt time.Time `json:"endedAt"`
+
+	// Marker is added to the card notes to find the card when the response
+	// to its creation is lost
+	Marker string `json:"marker"`
+
+	// CardID is set once the creation is known to have succeeded
+	CardID string `json:"cardId,omitempty"`
+}
+
+// IdempotencyJournal records card creations across calls, so a creation
+// whose outcome is unknown is checked before issuing another card.
+// Implementations must be safe for concurrent use.
+type IdempotencyJournal interface {
+	Get(key string) (*JournalEntry, error)
+	Put(entry JournalEntry) error
+	Delete(key string) error
+}
+
+// WithIdempotencyJournal replaces the in-memory journal of the client, e.g.
+// with a FileJournal surviving restarts. Processes issuing cards for the same
+// keys need a journal of their own backed by shared storage, such as a
+// database.
+func WithIdempotencyJournal(journal IdempotencyJournal) Option {
+	return func(c *Client) {
+		c.journal = journal
+	}
+}
+
+type MemoryJournal struct {
+	mu      sync.Mutex
+	entries map[string]JournalEntry
+}
+
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{entries: make(map[string]JournalEntry)}
+}
+
+func (j *MemoryJournal) Get(key string) (*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (j *MemoryJournal) Put(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[entry.Key] = entry
+	return nil
+}
+
+func (j *MemoryJournal) Delete(key string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.entries, key)
+	return nil
+}
+
+// FileJournal is a journal persisted as JSON, rewritten on every change. The
+// file is only read when opened, so it must not be shared by processes: they
+// wouldn't see each other's entries and would overwrite each other's changes.
+type FileJournal struct {
+	path    string
+	memory  *MemoryJournal
+	writeMu sync.Mutex
+}
+
+func NewFileJournal(path string) (*FileJournal, error) {
+	j := &FileJournal{path: path, memory: NewMemoryJournal()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	if err := json.Unmarshal(data, &j.memory.entries); err != nil {
+		return nil, fmt.Errorf("decode journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+func (j *FileJournal) Get(key string) (*JournalEntry, error) {
+	return j.memory.Get(key)
+}
+
+func (j *FileJournal) Put(entry JournalEntry) error {
+	j.memory.Put(entry)
+	return j.save()
+}
+
+func (j *FileJournal) Delete(key string) error {
+	j.memory.Delete(key)
+	return j.save()
+}
+
+func (j *FileJournal) save() error {
+	j.writeMu.Lock()
+	defer j.writeMu.Unlock()
+
+	j.memory.mu.Lock()
+	data, err := json.MarshalIndent(j.memory.entries, "", "  ")
+	j.memory.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// inFlight reports whether the creation of entry may still be running
+func (e JournalEntry) inFlight(now time.Time) bool {
+	return e.CardID == "" && e.EndedAt.IsZero() && now.Sub(e.StartedAt) < inFlightTimeout
+}
+
+// inflightCreate is a card creation running in this process, calls for the
+// same key wait for it instead of issuing concurrently
+type inflightCreate struct {
+	done chan struct{}
+	card *VirtualCard
+	err  error
+}
+
+// createOnce runs create unless a creation for key is already running in
+// this process, in which case it waits for it. With share, the card of the
+// running creation is returned; otherwise, or when it failed, create runs
+// once it's done. Derived keys must not share: identical calls without a key
+// each expect a card of their own.
+func (a *Client) createOnce(ctx context.Context, key string, share bool, create func() (*VirtualCard, error)) (*VirtualCard, error) {
+	for {
+		a.createsMu.Lock()
+		running, ok := a.creates[key]
+		if !ok {
+			break
+		}
+		a.createsMu.Unlock()
+
+		select {
+		case <-running.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if share && running.err == nil {
+			card := *running.card
+			return &card, nil
+		}
+	}
+
+	running := &inflightCreate{done: make(chan struct{})}
+	if a.creates == nil {
+		a.creates = make(map[string]*inflightCreate)
+	}
+	a.creates[key] = running
+	a.createsMu.Unlock()
+
+	defer func() {
+		a.createsMu.Lock()
+		delete(a.creates, key)
+		a.createsMu.Unlock()
+		close(running.done)
+	}()
+
+	running.card, running.err = create()
+	return running.card, running.err
+}
+
+// fingerprint identifies the card options, ignoring the idempotency key
+func (o CreateVirtualCardOptions) fingerprint() string {
+	// Nil pointers are encoded as null
+	recurrence, _ := json.Marshal(o.Recurrence)
+	mccControl, _ := json.Marshal(o.MCCControl)
+
+	h := sha256.New()
+	for _, field := range []string{
+		o.CreditCardID,
+		o.DisplayName,
+		strconv.Itoa(o.BalanceCents),
+		string(o.Currency),
+		o.Notes,
+		o.ValidFrom.Format(dateLayout),
+		o.ValidTo.Format(dateLayout),
+		o.Timezone,
+		o.Recipient,
+		string(recurrence),
+		string(mccControl),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func idempotencyMarker(key string) string {
+	return markerPrefix + key + "]"
+}
+
+// newJournalEntry starts an entry for key. Derived keys are the same for
+// identical cards created on purpose, so their marker also identifies the
+// entry.
+func newJournalEntry(key, fingerprint string, derived bool) *JournalEntry {
+	marker := key
+	if derived {
+		nonce := make([]byte, 8)
+		rand.Read(nonce)
+		marker += "-" + hex.EncodeToString(nonce)
+	}
+	return &JournalEntry{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Marker:      idempotencyMarker(marker),
+	}
+}
+
+// ambiguous reports whether a failed create may still have created a card:
+// the request may have reached Extend but its response was lost or was a
+// server error
+func ambiguous(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusRequestTimeout
+	}
+	return !errors.Is(err, ErrValidation)
+}
+
+// findMarkedCard looks for the card carrying the marker of entry among the
+// cards created since the entry started
+func (c *Client) findMarkedCard(ctx context.Context, entry JournalEntry) (*VirtualCard, error) {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), markerSearchTimeout)
+		defer cancel()
+	}
+
+	marker := entry.Marker
+	since := entry.StartedAt.Add(-markerSearchSkew)
+
+	cards := c.ListVirtualCards(&ListVirtualCardsOptions{
+		PaginationOptions: PaginationOptions{
+			Count:         markerSearchPageSize,
+			SortDirection: SortDirectionDesc,
+			SortField:     "createdAt",
+		},
+		Issued:   true,
+		Statuses: []VirtualCardStatus{VirtualCardStatusActive, VirtualCardStatusCancelled, VirtualCardStatusClosed},
+	})
+	for page := 0; page < markerSearchPages && cards.Next(); page++ {
+		response, err := cards.Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("search for card with idempotency key %s: %w", entry.Key, err)
+		}
+		for _, card := range response.Items() {
+			if strings.Contains(card.Notes, marker) {
+				return &card, nil
+			}
+			if card.CreatedAt != nil && card.CreatedAt.Before(since) {
+				return nil, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,215 @@
+package extend_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func newCardOptions(key string) extend.CreateVirtualCardOptions {
+	return extend.CreateVirtualCardOptions{
+		CreditCardID:   "cc_1",
+		DisplayName:    "Order",
+		BalanceCents:   5000,
+		ValidTo:        time.Now().AddDate(0, 1, 0),
+		IdempotencyKey: key,
+	}
+}
+
+// slowCreates delays card creations so concurrent calls overlap
+func slowCreates(next extend.Handler) extend.Handler {
+	return func(ctx context.Context, req *extend.Request) (*extend.Response, error) {
+		if req.Operation == "CreateVirtualCard" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return next(ctx, req)
+	}
+}
+
+func TestCreateVirtualCardConcurrentCalls(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		// cards is the number of distinct cards issued for three calls
+		cards int
+	}{
+		{"explicit key", "order-42", 1},
+		{"derived key", "", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			client := server.Client(extend.WithMiddleware(slowCreates))
+
+			var wg sync.WaitGroup
+			cards := make([]*extend.VirtualCard, 3)
+			errs := make([]error, len(cards))
+			for i := range cards {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					cards[i], errs[i] = client.CreateVirtualCard(context.Background(), newCardOptions(test.key))
+				}(i)
+			}
+			wg.Wait()
+
+			ids := map[string]bool{}
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("call %d: %v", i, err)
+				}
+				ids[cards[i].ID] = true
+			}
+			if len(ids) != test.cards {
+				t.Errorf("calls returned %d distinct cards, want %d", len(ids), test.cards)
+			}
+			if n := len(server.Cards()); n != test.cards {
+				t.Errorf("server holds %d cards, want %d", n, test.cards)
+			}
+		})
+	}
+}
+
+func TestCreateVirtualCardIdempotency(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// setup runs before the call under test
+		setup   func(t *testing.T, server *extendtest.Server, client *extend.Client)
+		options extend.CreateVirtualCardOptions
+		wantErr error
+		// cards is the number of cards the server holds after the call
+		cards int
+	}{
+		{
+			name:    "first call",
+			setup:   func(*testing.T, *extendtest.Server, *extend.Client) {},
+			options: newCardOptions("order-1"),
+			cards:   1,
+		},
+		{
+			name: "repeated key returns the card",
+			setup: func(t *testing.T, _ *extendtest.Server, client *extend.Client) {
+				if _, err := client.CreateVirtualCard(context.Background(), newCardOptions("order-1")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			options: newCardOptions("order-1"),
+			cards:   1,
+		},
+		{
+			name: "reused key",
+			setup: func(t *testing.T, _ *extendtest.Server, client *extend.Client) {
+				if _, err := client.CreateVirtualCard(context.Background(), newCardOptions("order-1")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			options: func() extend.CreateVirtualCardOptions {
+				options := newCardOptions("order-1")
+				options.BalanceCents = 9000
+				return options
+			}(),
+			wantErr: extend.ErrIdempotencyKeyReused,
+			cards:   1,
+		},
+		{
+			name: "lost response",
+			setup: func(_ *testing.T, server *extendtest.Server, _ *extend.Client) {
+				server.InjectFault(extendtest.Fault{Method: http.MethodPost, Path: "/virtualcards", StatusCode: http.StatusBadGateway, Handled: true, Times: 1})
+			},
+			options: newCardOptions("order-1"),
+			cards:   1,
+		},
+		{
+			name: "deliberate repeat without key",
+			setup: func(t *testing.T, _ *extendtest.Server, client *extend.Client) {
+				if _, err := client.CreateVirtualCard(context.Background(), newCardOptions("")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			options: newCardOptions(""),
+			cards:   2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			client := server.Client(extend.WithRetryPolicy(extend.RetryPolicy{MaxAttempts: 1}))
+
+			test.setup(t, server, client)
+			_, err := client.CreateVirtualCard(context.Background(), test.options)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if n := len(server.Cards()); n != test.cards {
+				t.Errorf("server holds %d cards, want %d", n, test.cards)
+			}
+		})
+	}
+}
+
+func TestCreateVirtualCardSharedJournal(t *testing.T) {
+	options := newCardOptions("order-7")
+
+	tests := []struct {
+		name      string
+		startedAt time.Time
+		endedAt   time.Time
+		wantErr   error
+		cards     int
+	}{
+		{"running elsewhere", time.Now(), time.Time{}, extend.ErrCreationInProgress, 0},
+		{"abandoned", time.Now().Add(-time.Hour), time.Time{}, nil, 1},
+		{"failed ambiguously", time.Now(), time.Now(), nil, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+
+			journal, err := extend.NewFileJournal(filepath.Join(t.TempDir(), "journal.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// An entry left by another client with the same options. Both
+			// clients share the journal instance, like processes sharing a
+			// journal backed by a database.
+			other := server.Client(extend.WithIdempotencyJournal(journal), extend.WithMiddleware(func(next extend.Handler) extend.Handler {
+				return func(ctx context.Context, req *extend.Request) (*extend.Response, error) {
+					if req.Operation == "CreateVirtualCard" {
+						return nil, context.DeadlineExceeded
+					}
+					return next(ctx, req)
+				}
+			}))
+			other.CreateVirtualCard(context.Background(), options)
+			entry, err := journal.Get(options.IdempotencyKey)
+			if err != nil || entry == nil {
+				t.Fatalf("no journal entry: %v", err)
+			}
+			entry.StartedAt, entry.EndedAt = test.startedAt, test.endedAt
+			journal.Put(*entry)
+
+			client := server.Client(extend.WithIdempotencyJournal(journal))
+			_, err = client.CreateVirtualCard(context.Background(), options)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if n := len(server.Cards()); n != test.cards {
+				t.Errorf("server holds %d cards, want %d", n, test.cards)
+			}
+		})
+	}
+}
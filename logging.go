@@ -0,0 +1,81 @@
+package extend
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Redacted replaces secrets in logs and formatted values
+const Redacted = "[REDACTED]"
+
+// WithLogger makes the client log every call with its operation, status,
+// latency and card ID. Secrets such as the access token, card numbers and
+// security codes are never logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+func (c *Client) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (c *Client) logRequest(ctx context.Context, req *Request, res *Response, err error, latency time.Duration) {
+	if c.logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", req.Operation),
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.Duration("latency", latency),
+	}
+	if req.ResourceID != "" {
+		attrs = append(attrs, slog.String("resource_id", req.ResourceID))
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode), slog.Int("attempts", res.Attempts))
+	}
+
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			attrs = append(attrs, slog.Int("status", apiErr.StatusCode))
+			if apiErr.RequestID != "" {
+				attrs = append(attrs, slog.String("request_id", apiErr.RequestID))
+			}
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+		c.log(ctx, slog.LevelError, "extend request failed", attrs...)
+		return
+	}
+
+	c.log(ctx, slog.LevelInfo, "extend request", attrs...)
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// LogValue logs a summary of the card
+func (v VirtualCard) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", v.ID),
+		slog.String("status", string(v.Status)),
+		slog.String("display_name", v.DisplayName),
+		slog.String("last4", v.Last4),
+		slog.Int("balance_cents", v.BalanceCents),
+		slog.Int("limit_cents", v.LimitCents),
+		slog.String("credit_card_id", v.CreditCardID),
+	)
+}
@@ -0,0 +1,97 @@
+package extend_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	server := extendtest.NewServer()
+	defer server.Close()
+	seeded := server.SeedCard(extend.VirtualCard{
+		DisplayName:  "Travel",
+		CreditCardID: "cc_1",
+		BalanceCents: 1000,
+		ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+	})
+	number, securityCode, _ := server.CardNumber(seeded.ID)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := server.Client(extend.WithLogger(logger))
+	ctx := context.Background()
+
+	revealed, err := client.RevealVirtualCard(ctx, seeded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("revealed card", "card", revealed)
+	card, err := client.GetVirtualCard(ctx, seeded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("card", "card", card)
+	// A failed call logs its error
+	server.InjectFault(extendtest.Fault{StatusCode: 400, Body: fmt.Sprintf(`{"error":"bad request","vcn":%q}`, number), Times: 1})
+	client.GetVirtualCard(ctx, seeded.ID)
+
+	out := logs.String()
+	if !strings.Contains(out, `"operation":"RevealVirtualCard"`) || !strings.Contains(out, seeded.ID) {
+		t.Errorf("calls weren't logged:\n%s", out)
+	}
+	for _, secret := range []string{extendtest.DefaultToken, number, fmt.Sprintf("%q", securityCode)} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logs contain %q:\n%s", secret, out)
+		}
+	}
+}
+
+func TestRevealedCardFormatting(t *testing.T) {
+	card := extend.RevealedCard{
+		VirtualCardID: "vc_1",
+		Number:        "4111111111111111",
+		SecurityCode:  "123",
+		Expires:       time.Date(2027, 3, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		format func() string
+	}{
+		{"%v", func() string { return fmt.Sprintf("%v", card) }},
+		{"%+v", func() string { return fmt.Sprintf("%+v", card) }},
+		{"%#v", func() string { return fmt.Sprintf("%#v", card) }},
+		{"%s", func() string { return fmt.Sprintf("%s", card) }},
+		{"pointer", func() string { return fmt.Sprintf("%v", &card) }},
+		{"slog text", func() string {
+			var out bytes.Buffer
+			slog.New(slog.NewTextHandler(&out, nil)).Info("card", "card", card)
+			return out.String()
+		}},
+		{"slog JSON", func() string {
+			var out bytes.Buffer
+			slog.New(slog.NewJSONHandler(&out, nil)).Info("card", "card", card)
+			return out.String()
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := test.format()
+			if strings.Contains(out, card.Number) || strings.Contains(out, "123") {
+				t.Errorf("got %s", out)
+			}
+			if !strings.Contains(out, "vc_1") || !strings.Contains(out, extend.Redacted) || !strings.Contains(out, "03/2027") {
+				t.Errorf("got %s, want the card ID, expiry and %s", out, extend.Redacted)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package extend
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// MCCControlMode is how a card treats the merchant categories of its controls
+type MCCControlMode string
+
+const (
+	// MCCControlAllow only allows the listed categories
+	MCCControlAllow MCCControlMode = "INCLUDE"
+
+	// MCCControlBlock declines the listed categories
+	MCCControlBlock MCCControlMode = "EXCLUDE"
+)
+
+// MCCControl restricts the merchant categories a card can be used at. MCCs
+// are 4 digit merchant category codes and MCCGroups the IDs of the named
+// groups returned by ListMCCGroups.
+type MCCControl struct {
+	Mode      MCCControlMode `json:"mode"`
+	MCCs      []string       `json:"mccs,omitempty"`
+	MCCGroups []string       `json:"mccGroups,omitempty"`
+}
+
+// empty reports whether the controls don't restrict anything, which removes
+// the controls of a card on update
+func (m MCCControl) empty() bool {
+	return m.Mode == "" && len(m.MCCs) == 0 && len(m.MCCGroups) == 0
+}
+
+// Allows reports whether a merchant category code passes the controls. The
+// codes of groups are taken from groups.
+func (m MCCControl) Allows(mcc string, groups []MCCGroup) bool {
+	listed := slices.Contains(m.MCCs, mcc)
+	for _, group := range groups {
+		if slices.Contains(m.MCCGroups, group.ID) && group.contains(mcc) {
+			listed = true
+		}
+	}
+	if m.Mode == MCCControlAllow {
+		return listed
+	}
+	return !listed
+}
+
+func (m MCCControl) validate() error {
+	if m.Mode != MCCControlAllow && m.Mode != MCCControlBlock {
+		return validationError("invalid MCC control mode %q", m.Mode)
+	}
+	if len(m.MCCs) == 0 && len(m.MCCGroups) == 0 {
+		return validationError("MCC control lists no merchant category")
+	}
+	for _, mcc := range m.MCCs {
+		if !validMCC(mcc) {
+			return validationError("invalid merchant category code %q", mcc)
+		}
+	}
+	return nil
+}
+
+func validMCC(mcc string) bool {
+	if len(mcc) != 4 {
+		return false
+	}
+	for _, r := range mcc {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type MCC struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// MCCGroup is a named set of merchant categories, e.g. travel or software
+type MCCGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MCCs        []MCC  `json:"mccs"`
+}
+
+func (g MCCGroup) contains(mcc string) bool {
+	return slices.ContainsFunc(g.MCCs, func(m MCC) bool { return m.Code == mcc })
+}
+
+type ListMCCGroupsResponse struct {
+	MCCGroups []MCCGroup `json:"mccGroups"`
+}
+
+// ListMCCGroups returns the named merchant category groups usable in MCC
+// controls
+func (c *Client) ListMCCGroups(ctx context.Context) ([]MCCGroup, error) {
+	var response ListMCCGroupsResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation: "ListMCCGroups",
+		Method:    http.MethodGet,
+		Path:      "/mccgroups",
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.MCCGroups, nil
+}
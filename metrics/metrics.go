@@ -0,0 +1,187 @@
+// Package metrics exports Extend API and authentication activity in the
+// Prometheus text format.
+//
+//	m := metrics.New()
+//	auth.SetAuthObserver(m.ObserveAuth)
+//	m.WatchAuthenticator("bot", auth)
+//	client := extend.New(auth, extend.WithMiddleware(m.Middleware))
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"local/extend"
+	"local/extend/cognito"
+)
+
+var (
+	// requestBuckets are the latency buckets, in seconds, of Extend calls.
+	// Calls include retries so the buckets go up to a minute.
+	requestBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+	// authBuckets are the latency buckets, in seconds, of Cognito logins
+	// and refreshes. A login is four round trips.
+	authBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16}
+)
+
+type Metrics struct {
+	requests        *counterVec
+	requestDuration *histogramVec
+
+	auth         *counterVec
+	authFailures *counterVec
+	authDuration *histogramVec
+
+	cardsCreated   *counterVec
+	cardsClosed    *counterVec
+	cardsCancelled *counterVec
+	centsIssued    *counterVec
+
+	mu             sync.Mutex
+	authenticators map[string]extend.Authenticator
+
+	collectors []collector
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		requests:        newCounterVec("extend_requests_total", "Extend API calls by operation and HTTP status, \"error\" when no response was received.", "operation", "status"),
+		requestDuration: newHistogramVec("extend_request_duration_seconds", "Duration of Extend API calls, including retries.", requestBuckets, "operation", "status"),
+		auth:            newCounterVec("extend_auth_total", "Cognito logins and refreshes.", "kind"),
+		authFailures:    newCounterVec("extend_auth_failures_total", "Failed Cognito logins and refreshes.", "kind"),
+		authDuration:    newHistogramVec("extend_auth_duration_seconds", "Duration of Cognito logins and refreshes.", authBuckets, "kind"),
+		cardsCreated:    newCounterVec("extend_virtual_cards_created_total", "Virtual cards created, including bulk uploads and approved requests.", "operation"),
+		cardsClosed:     newCounterVec("extend_virtual_cards_closed_total", "Virtual cards closed."),
+		cardsCancelled:  newCounterVec("extend_virtual_cards_cancelled_total", "Virtual cards cancelled."),
+		centsIssued:     newCounterVec("extend_issued_cents_total", "Balance of created virtual cards, in cents.", "currency"),
+		authenticators:  make(map[string]extend.Authenticator),
+	}
+
+	m.collectors = []collector{
+		m.requests,
+		m.requestDuration,
+		m.auth,
+		m.authFailures,
+		m.authDuration,
+		&gaugeFunc{
+			name:    "extend_token_expiry_seconds",
+			help:    "Seconds until the access token of an authenticator expires, negative once expired.",
+			labels:  []string{"authenticator"},
+			collect: m.tokenExpiry,
+		},
+		m.cardsCreated,
+		m.cardsClosed,
+		m.cardsCancelled,
+		m.centsIssued,
+	}
+
+	return m
+}
+
+// Middleware records every call of a client, use it with
+// extend.WithMiddleware
+func (m *Metrics) Middleware(next extend.Handler) extend.Handler {
+	return func(ctx context.Context, req *extend.Request) (*extend.Response, error) {
+		start := time.Now()
+		res, err := next(ctx, req)
+
+		status := "error"
+		var apiErr *extend.APIError
+		switch {
+		case res != nil:
+			status = strconv.Itoa(res.StatusCode)
+		case errors.As(err, &apiErr):
+			status = strconv.Itoa(apiErr.StatusCode)
+		}
+
+		m.requests.inc(req.Operation, status)
+		m.requestDuration.observe(time.Since(start).Seconds(), req.Operation, status)
+
+		if err == nil && res != nil && !res.DryRun {
+			m.recordCards(req)
+		}
+
+		return res, err
+	}
+}
+
+func (m *Metrics) recordCards(req *extend.Request) {
+	switch result := req.Result.(type) {
+	case *extend.VirtualCardResponse:
+		switch req.Operation {
+		case "CreateVirtualCard", "ApproveVirtualCardRequest":
+			m.cardsCreated.inc(req.Operation)
+			m.centsIssued.add(float64(result.VirtualCard.BalanceCents), result.VirtualCard.Currency)
+		case "CloseVirtualCard":
+			m.cardsClosed.inc()
+		case "CancelVirtualCard":
+			m.cardsCancelled.inc()
+		}
+	case *extend.BulkVirtualCardPushResponse:
+		for _, task := range result.BulkVirtualCardPush.Tasks {
+			m.cardsCreated.inc(req.Operation)
+			m.centsIssued.add(float64(task.Record.BalanceCents), task.Record.Currency)
+		}
+	}
+}
+
+// ObserveAuth records a Cognito login or refresh, use it with
+// cognito.Cognito.SetAuthObserver
+func (m *Metrics) ObserveAuth(event cognito.AuthEvent) {
+	kind := string(event.Kind)
+	m.auth.inc(kind)
+	if event.Err != nil {
+		m.authFailures.inc(kind)
+	}
+	m.authDuration.observe(event.Duration.Seconds(), kind)
+}
+
+// WatchAuthenticator exports the time until the access token of auth
+// expires under the given name
+func (m *Metrics) WatchAuthenticator(name string, auth extend.Authenticator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authenticators[name] = auth
+}
+
+func (m *Metrics) tokenExpiry() []sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.authenticators))
+	for name := range m.authenticators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	samples := make([]sample, 0, len(names))
+	for _, name := range names {
+		expiry := m.authenticators[name].Expiry()
+		if expiry.IsZero() {
+			// Not logged in yet
+			continue
+		}
+		samples = append(samples, sample{
+			labels: []string{name},
+			value:  time.Until(expiry).Seconds(),
+		})
+	}
+	return samples
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, c := range m.collectors {
+		if err := c.write(w); err != nil {
+			return
+		}
+	}
+}
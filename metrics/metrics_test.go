@@ -0,0 +1,216 @@
+package metrics_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/cognito"
+	"local/extend/cognito/cognitotest"
+	"local/extend/extendtest"
+	"local/extend/metrics"
+)
+
+// scrape returns the samples exposed by m, keyed by series
+func scrape(t *testing.T, m *metrics.Metrics) map[string]float64 {
+	t.Helper()
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q", contentType)
+	}
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("invalid sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestMiddleware(t *testing.T) {
+	validTo := time.Now().AddDate(0, 1, 0)
+	create := func(ctx context.Context, client *extend.Client) {
+		client.CreateVirtualCard(ctx, extend.CreateVirtualCardOptions{
+			CreditCardID: "cc_1",
+			DisplayName:  "Travel",
+			BalanceCents: 2500,
+			ValidTo:      validTo,
+		})
+	}
+
+	tests := []struct {
+		name    string
+		options []extend.Option
+		call    func(ctx context.Context, client *extend.Client)
+		want    map[string]float64
+		// absent are series that must not be exposed
+		absent []string
+	}{
+		{
+			name: "created",
+			call: create,
+			want: map[string]float64{
+				`extend_requests_total{operation="CreateVirtualCard",status="200"}`:                            1,
+				`extend_request_duration_seconds_count{operation="CreateVirtualCard",status="200"}`:            1,
+				`extend_request_duration_seconds_bucket{operation="CreateVirtualCard",status="200",le="+Inf"}`: 1,
+				`extend_virtual_cards_created_total{operation="CreateVirtualCard"}`:                            1,
+				`extend_issued_cents_total{currency="USD"}`:                                                    2500,
+			},
+		},
+		{
+			name:    "dry run",
+			options: []extend.Option{extend.WithDryRun(nil)},
+			call:    create,
+			want: map[string]float64{
+				`extend_requests_total{operation="CreateVirtualCard",status="200"}`: 1,
+			},
+			absent: []string{
+				`extend_virtual_cards_created_total{operation="CreateVirtualCard"}`,
+				`extend_issued_cents_total{currency="USD"}`,
+			},
+		},
+		{
+			name: "API error",
+			call: func(ctx context.Context, client *extend.Client) {
+				client.GetVirtualCard(ctx, "vc_missing")
+			},
+			want: map[string]float64{
+				`extend_requests_total{operation="GetVirtualCard",status="404"}`: 1,
+			},
+		},
+		{
+			name: "no response",
+			call: func(ctx context.Context, client *extend.Client) {
+				ctx, cancel := context.WithCancel(ctx)
+				cancel()
+				client.GetVirtualCard(ctx, "vc_1")
+			},
+			want: map[string]float64{
+				`extend_requests_total{operation="GetVirtualCard",status="error"}`: 1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			m := metrics.New()
+			client := server.Client(append(test.options, extend.WithMiddleware(m.Middleware))...)
+
+			test.call(context.Background(), client)
+
+			samples := scrape(t, m)
+			for series, want := range test.want {
+				if got, ok := samples[series]; !ok || got != want {
+					t.Errorf("got %s = %v (exposed %t), want %v", series, got, ok, want)
+				}
+			}
+			for _, series := range test.absent {
+				if got, ok := samples[series]; ok {
+					t.Errorf("got %s = %v, want none", series, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		failures  int
+		// buckets are the cumulative counts of the le="0.25", "1", "4" and
+		// "16" buckets
+		buckets [4]float64
+	}{
+		{"one fast login", []time.Duration{100 * time.Millisecond}, 0, [4]float64{1, 1, 1, 1}},
+		{"spread", []time.Duration{200 * time.Millisecond, 800 * time.Millisecond, 3 * time.Second, 10 * time.Second}, 0, [4]float64{1, 2, 3, 4}},
+		{"beyond the last bucket", []time.Duration{time.Second, time.Minute}, 1, [4]float64{0, 1, 1, 1}},
+		{"on a bound", []time.Duration{time.Second, 4 * time.Second}, 0, [4]float64{0, 1, 2, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := metrics.New()
+			for i, duration := range test.durations {
+				event := cognito.AuthEvent{Kind: cognito.AuthEventLogin, Duration: duration}
+				if i < test.failures {
+					event.Err = context.DeadlineExceeded
+				}
+				m.ObserveAuth(event)
+			}
+
+			samples := scrape(t, m)
+			count := float64(len(test.durations))
+			for i, le := range []string{"0.25", "1", "4", "16"} {
+				series := `extend_auth_duration_seconds_bucket{kind="login",le="` + le + `"}`
+				if got := samples[series]; got != test.buckets[i] {
+					t.Errorf("got %s = %v, want %v", series, got, test.buckets[i])
+				}
+			}
+
+			previous := 0.0
+			for _, le := range []string{"0.1", "0.25", "0.5", "1", "2", "4", "8", "16", "+Inf"} {
+				got := samples[`extend_auth_duration_seconds_bucket{kind="login",le="`+le+`"}`]
+				if got < previous {
+					t.Errorf("bucket le=%s = %v is below the previous one %v", le, got, previous)
+				}
+				previous = got
+			}
+			if inf, n := samples[`extend_auth_duration_seconds_bucket{kind="login",le="+Inf"}`], samples[`extend_auth_duration_seconds_count{kind="login"}`]; inf != count || n != count {
+				t.Errorf("got +Inf bucket %v and count %v, want %v", inf, n, count)
+			}
+			if got := samples[`extend_auth_total{kind="login"}`]; got != count {
+				t.Errorf("got %v logins, want %v", got, count)
+			}
+			if got := samples[`extend_auth_failures_total{kind="login"}`]; got != float64(test.failures) {
+				t.Errorf("got %v failures, want %d", got, test.failures)
+			}
+		})
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	idp := cognitotest.NewServer()
+	defer idp.Close()
+
+	m := metrics.New()
+	m.WatchAuthenticator("bot \"main\"\\\n", extendtest.Token(extendtest.DefaultToken))
+	// Not logged in yet, so without an expiry
+	m.WatchAuthenticator("idle", idp.NewCognito(cognito.AuthParams{Username: "idle@example.com"}))
+
+	samples := scrape(t, m)
+	series := `extend_token_expiry_seconds{authenticator="bot \"main\"\\\n"}`
+	if got, ok := samples[series]; !ok || got < 3500 || got > 3600 {
+		t.Errorf("got %s = %v (exposed %t), want about an hour", series, got, ok)
+	}
+	if got, ok := samples[`extend_token_expiry_seconds{authenticator="idle"}`]; ok {
+		t.Errorf("got expiry %v for an authenticator that never logged in", got)
+	}
+}
@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector is a metric family written in the Prometheus text format
+type collector interface {
+	write(w io.Writer) error
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+func newCounterVec(name string, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*counterValue),
+	}
+}
+
+func (c *counterVec) add(delta float64, labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.Join(labels, "\xff")
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: labels}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *counterVec) inc(labels ...string) {
+	c.add(1, labels...)
+}
+
+func (c *counterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := writeHeader(w, c.name, c.help, "counter")
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		_, err = fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, v.labels), formatFloat(v.value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+}
+
+func (h *histogramVec) observe(value float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labels, "\xff")
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := writeHeader(w, h.name, h.help, "histogram")
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		names := append(append([]string{}, h.labels...), "le")
+		for i, bound := range h.buckets {
+			values := append(append([]string{}, v.labels...), formatFloat(bound))
+			_, err = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), v.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+		values := append(append([]string{}, v.labels...), "+Inf")
+		_, err = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), v.count)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			h.name, formatLabels(h.labels, v.labels), formatFloat(v.sum),
+			h.name, formatLabels(h.labels, v.labels), v.count,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gaugeFunc is a gauge whose samples are computed when metrics are scraped
+type gaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []sample
+}
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	err := writeHeader(w, g.name, g.help, "gauge")
+	if err != nil {
+		return err
+	}
+	for _, s := range g.collect() {
+		_, err = fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, s.labels), formatFloat(s.value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, kind)
+	return err
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(values[i]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package extend
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request describes a single call of the Extend API as seen by middleware
+type Request struct {
+	// Operation is the name of the client method making the call, e.g.
+	// "CreateVirtualCard"
+	Operation string
+
+	Method string
+
+	// Path is relative to the API base URL and includes the query string
+	Path string
+
+	// ResourceID is the ID of the card, credit card or upload the call
+	// touches, empty for calls that don't target a single resource
+	ResourceID string
+
+	// Header is sent in addition to the brand headers
+	Header http.Header
+
+	ContentType string
+	Body        []byte
+
+	// NonIdempotent is set on calls that must not be replayed once they may
+	// have reached Extend, whatever their method, e.g. approving a card
+	// request issues a funded card
+	NonIdempotent bool
+
+	// Result is the value the response body is decoded into, nil when the
+	// response is discarded. It is populated once the next handler returns.
+	Result any
+
+	// synthetic builds the result returned in dry-run mode
+	synthetic func(ctx context.Context) (any, error)
+}
+
+// Response is the final response of a call, after retries
+type Response struct {
+	StatusCode int
+	Header     http.Header
+
+	// Body is the raw response, with card numbers and security codes
+	// replaced by Redacted
+	Body []byte
+
+	// Attempts is the number of HTTP requests sent for the call
+	Attempts int
+
+	// DryRun is set when the request wasn't sent and Body is synthetic
+	DryRun bool
+}
+
+// Handler performs a call. Errors returned for non 2xx responses are
+// *APIError.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps every call made by the client, e.g. for logging, metrics
+// or fault injection. A middleware may change the request, skip next and
+// return its own response or error.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware appends middleware to the chain. The first middleware is
+// the outermost one and sees calls first.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// Use appends middleware to the chain, see WithMiddleware
+func (c *Client) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+func (c *Client) chain() Handler {
+	handler := c.handle
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.middleware[i](handler)
+	}
+	return handler
+}
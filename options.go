@@ -0,0 +1,86 @@
+package extend
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAPIVersion is the Extend API version requested through the
+	// Accept header
+	DefaultAPIVersion = "2021-03-12"
+)
+
+type Option func(*Client)
+
+// WithBrand replaces the base URL and the browser fingerprint headers sent
+// with every request
+func WithBrand(brand ExtendPlatformBrand) Option {
+	return func(c *Client) {
+		c.brand = brand.clone()
+	}
+}
+
+// WithBaseURL points the client at another API host, e.g. a staging
+// environment or a local test server
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.brand.APIBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAPIVersion sets the API version requested through the Accept header,
+// e.g. "2021-03-12"
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		c.apiVersion = version
+	}
+}
+
+// WithHeader sets a header sent with every request, replacing any header of
+// the brand fingerprint with the same name regardless of its case
+func WithHeader(key string, value string) Option {
+	return func(c *Client) {
+		setHeader(c.brand.Header, key, value)
+	}
+}
+
+// WithUserAgent overrides the user agent of the spoofed browser fingerprint
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		setHeader(c.brand.Header, "user-agent", userAgent)
+		setHeader(c.brand.Header, "x-extend-platform-version", userAgent)
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithTimeout limits the duration of each attempt of a request, including
+// reading the response body
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// setHeader sets a header without canonicalizing its name, removing other
+// spellings of the same name first
+func setHeader(header http.Header, key string, values ...string) {
+	for k := range header {
+		if strings.EqualFold(k, key) {
+			delete(header, k)
+		}
+	}
+	header[key] = values
+}
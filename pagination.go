@@ -9,18 +9,19 @@ import (
 )
 
 type Paginator[T any, R PaginatedResponse[T]] struct {
-	hasNext  bool
-	nextPage int
-	api      *Client
-	path     string
-	query    url.Values
+	hasNext   bool
+	nextPage  int
+	api       *Client
+	operation string
+	path      string
+	query     url.Values
 }
 
-func newPaginator[T any, R PaginatedResponse[T]](api *Client, options PaginationOptions, path string, query url.Values) *Paginator[T, R] {
+func newPaginator[T any, R PaginatedResponse[T]](api *Client, operation string, options PaginationOptions, path string, query url.Values) *Paginator[T, R] {
 	query.Set("count", strconv.Itoa(options.Count))
 	query.Set("sortDirection", string(options.SortDirection))
 	query.Set("sortField", options.SortField)
-	return &Paginator[T, R]{true, options.Page, api, path, query}
+	return &Paginator[T, R]{true, options.Page, api, operation, path, query}
 }
 
 func (p *Paginator[T, R]) Next() bool {
@@ -36,7 +37,11 @@ func (p *Paginator[T, R]) Get(ctx context.Context) (*R, error) {
 	p.nextPage++
 
 	var response R
-	err := p.api.jsonRequest(ctx, http.MethodGet, p.path+"?"+p.query.Encode(), nil, &response)
+	err := p.api.jsonRequest(ctx, &Request{
+		Operation: p.operation,
+		Method:    http.MethodGet,
+		Path:      p.path + "?" + p.query.Encode(),
+	}, nil, &response)
 	if err != nil {
 		return nil, err
 	}
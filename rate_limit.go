@@ -0,0 +1,234 @@
+package extend
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	// Rate is the sustained number of requests per second
+	Rate float64
+
+	// Burst is the number of requests that can be sent at once after a
+	// period of inactivity
+	Burst int
+}
+
+var (
+	// DefaultRateLimit is applied to all requests of a client unless
+	// configured otherwise
+	DefaultRateLimit = RateLimit{Rate: 5, Burst: 10}
+
+	// defaultRateLimitPause is how long a route is paused after a 429
+	// response without Retry-After
+	defaultRateLimitPause = time.Second
+)
+
+// RateLimiter is a token bucket limiter with a global bucket and optional
+// per route buckets. A request waits for a token from both. Routes are the
+// first segment of the request path, e.g. "/virtualcards" or
+// "/bulkvirtualcarduploads".
+//
+// A RateLimiter is safe for concurrent use and can be shared by several
+// clients using the same account through WithRateLimiter.
+type RateLimiter struct {
+	mu     sync.Mutex
+	global *bucket
+	routes map[string]*bucket
+}
+
+func NewRateLimiter(global RateLimit, routes map[string]RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		global: newBucket(global),
+		routes: make(map[string]*bucket, len(routes)),
+	}
+	for route, limit := range routes {
+		l.routes[routeOf(route)] = newBucket(limit)
+	}
+	return l
+}
+
+// WithRateLimit replaces the global rate limit of the client. With a
+// limiter set by WithRateLimiter, it applies to this client only on top of
+// that limiter.
+func WithRateLimit(limit RateLimit) Option {
+	return func(c *Client) {
+		c.rateLimit = &limit
+	}
+}
+
+// WithRouteRateLimit limits requests to a route, e.g. "/virtualcards", in
+// addition to the global limit. With a limiter set by WithRateLimiter, it
+// applies to this client only on top of that limiter.
+func WithRouteRateLimit(route string, limit RateLimit) Option {
+	return func(c *Client) {
+		if c.routeLimits == nil {
+			c.routeLimits = make(map[string]RateLimit)
+		}
+		c.routeLimits[route] = limit
+	}
+}
+
+// WithRateLimiter makes the client use limiter, typically shared with other
+// clients, instead of DefaultRateLimit. The limiter isn't changed by the
+// other rate limit options. Passing nil disables the default limit.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.sharedLimiter = limiter
+		c.sharedLimiterSet = true
+	}
+}
+
+// newLimiter builds the limiter of the client once the options are applied,
+// nil when the client has no limits of its own
+func (c *Client) newLimiter() *RateLimiter {
+	global := DefaultRateLimit
+	if c.sharedLimiterSet {
+		if c.rateLimit == nil && len(c.routeLimits) == 0 {
+			return nil
+		}
+		// Unlimited, the shared limiter bounds the client if any
+		global = RateLimit{}
+	}
+	if c.rateLimit != nil {
+		global = *c.rateLimit
+	}
+	return NewRateLimiter(global, c.routeLimits)
+}
+
+// waitRateLimit waits for the shared limiter then for the client's own
+func (c *Client) waitRateLimit(ctx context.Context, path string) error {
+	if err := c.sharedLimiter.Wait(ctx, path); err != nil {
+		return err
+	}
+	return c.limiter.Wait(ctx, path)
+}
+
+func (c *Client) pauseRateLimit(path string, d time.Duration) {
+	c.sharedLimiter.Pause(path, d)
+	c.limiter.Pause(path, d)
+}
+
+// Wait blocks until a request to path is allowed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, path string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	buckets := []*bucket{l.global}
+	if route, ok := l.routes[routeOf(path)]; ok {
+		buckets = append(buckets, route)
+	}
+	var delay time.Duration
+	for _, b := range buckets {
+		if d := b.reserve(now); d > delay {
+			delay = d
+		}
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	err := sleep(ctx, delay)
+	if err != nil {
+		// Give the tokens back, the request won't be sent
+		l.mu.Lock()
+		for _, b := range buckets {
+			b.cancel()
+		}
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// Pause stops requests to the route of path for d, or the whole client when
+// the route has no limit of its own. It is called when Extend answers with
+// 429 so concurrent requests back off together.
+func (l *RateLimiter) Pause(path string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	if d <= 0 {
+		d = defaultRateLimitPause
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.routes[routeOf(path)]
+	if !ok {
+		b = l.global
+	}
+	b.pause(time.Now().Add(d))
+}
+
+// routeOf returns the first segment of a request path, without query
+func routeOf(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	path = "/" + strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(path[1:], '/'); i >= 0 {
+		return path[:i+1]
+	}
+	return path
+}
+
+type bucket struct {
+	limit       RateLimit
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newBucket(limit RateLimit) *bucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &bucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it. Tokens go negative while requests are queued.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if b.limit.Rate <= 0 {
+		// Unlimited, only a pause holds requests back
+		return max(b.pausedUntil.Sub(now), 0)
+	}
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+	}
+	b.last = now
+	b.tokens--
+
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+	}
+	if wait := b.pausedUntil.Sub(now); wait > delay {
+		delay = wait
+	}
+	return delay
+}
+
+func (b *bucket) cancel() {
+	if b.limit.Rate > 0 {
+		b.tokens++
+	}
+}
+
+func (b *bucket) pause(until time.Time) {
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
@@ -0,0 +1,143 @@
+package extend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		limit  RateLimit
+		paused time.Duration
+		// taken is the number of tokens taken before the reservation
+		taken int
+		want  time.Duration
+	}{
+		{"unlimited", RateLimit{}, 0, 5, 0},
+		{"unlimited paused", RateLimit{}, 2 * time.Second, 0, 2 * time.Second},
+		{"within burst", RateLimit{Rate: 1, Burst: 3}, 0, 2, 0},
+		{"burst used", RateLimit{Rate: 2, Burst: 2}, 0, 2, 500 * time.Millisecond},
+		{"paused longer than the rate", RateLimit{Rate: 2, Burst: 2}, 3 * time.Second, 2, 3 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBucket(test.limit)
+			for i := 0; i < test.taken; i++ {
+				b.reserve(now)
+			}
+			if test.paused > 0 {
+				b.pause(now.Add(test.paused))
+			}
+			if got := b.reserve(now); got != test.want {
+				t.Errorf("got delay %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitOptions(t *testing.T) {
+	shared := NewRateLimiter(RateLimit{Rate: 10, Burst: 20}, nil)
+
+	tests := []struct {
+		name    string
+		options []Option
+		// own is the global limit of the client's own limiter, nil when it
+		// has none
+		own    *RateLimit
+		shared *RateLimiter
+	}{
+		{
+			name: "default",
+			own:  &DefaultRateLimit,
+		},
+		{
+			name:    "global limit",
+			options: []Option{WithRateLimit(RateLimit{Rate: 1, Burst: 1})},
+			own:     &RateLimit{Rate: 1, Burst: 1},
+		},
+		{
+			name:    "shared limiter",
+			options: []Option{WithRateLimiter(shared)},
+			shared:  shared,
+		},
+		{
+			name:    "global limit after shared limiter",
+			options: []Option{WithRateLimiter(shared), WithRateLimit(RateLimit{Rate: 0.001, Burst: 1})},
+			own:     &RateLimit{Rate: 0.001, Burst: 1},
+			shared:  shared,
+		},
+		{
+			name:    "route limit after shared limiter",
+			options: []Option{WithRateLimiter(shared), WithRouteRateLimit("/virtualcards", RateLimit{Rate: 1, Burst: 1})},
+			own:     &RateLimit{Burst: 1},
+			shared:  shared,
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithRateLimiter(nil)},
+		},
+		{
+			name:    "global limit after disabled",
+			options: []Option{WithRateLimiter(nil), WithRateLimit(RateLimit{Rate: 1, Burst: 1})},
+			own:     &RateLimit{Rate: 1, Burst: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := New(nil, test.options...)
+
+			switch {
+			case test.own == nil && c.limiter != nil:
+				t.Errorf("got own limit %+v, want none", c.limiter.global.limit)
+			case test.own != nil && c.limiter == nil:
+				t.Errorf("got no own limit, want %+v", *test.own)
+			case test.own != nil && c.limiter.global.limit != *test.own:
+				t.Errorf("got own limit %+v, want %+v", c.limiter.global.limit, *test.own)
+			}
+			if c.sharedLimiter != test.shared {
+				t.Errorf("got shared limiter %p, want %p", c.sharedLimiter, test.shared)
+			}
+			if shared.global.limit != (RateLimit{Rate: 10, Burst: 20}) || len(shared.routes) != 0 {
+				t.Errorf("shared limiter changed to %+v with %d routes", shared.global.limit, len(shared.routes))
+			}
+		})
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	tests := []struct {
+		name   string
+		global RateLimit
+		routes map[string]RateLimit
+		// paused and other are paths, the pause on paused must hold back
+		// other when it shares its bucket
+		paused  string
+		other   string
+		blocked bool
+	}{
+		{"unlimited", RateLimit{}, nil, "/virtualcards", "/transactions", true},
+		{"global", RateLimit{Rate: 100, Burst: 10}, nil, "/virtualcards", "/transactions", true},
+		{"route", RateLimit{Rate: 100, Burst: 10}, map[string]RateLimit{"/virtualcards": {Rate: 100, Burst: 10}}, "/virtualcards/vc_1", "/transactions", false},
+		{"unlimited route", RateLimit{Rate: 100, Burst: 10}, map[string]RateLimit{"/virtualcards": {}}, "/virtualcards/vc_1", "/virtualcards", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewRateLimiter(test.global, test.routes)
+			l.Pause(test.paused, time.Hour)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			err := l.Wait(ctx, test.other)
+			if blocked := err != nil; blocked != test.blocked {
+				t.Errorf("got blocked %t, want %t", blocked, test.blocked)
+			}
+		})
+	}
+}
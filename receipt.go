@@ -0,0 +1,136 @@
+package extend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+// receiptExtensions are the receipt content types accepted by Extend and the
+// extension of the uploaded file name
+var receiptExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/heic":      ".heic",
+}
+
+type ReceiptAttachment struct {
+	ID            string `json:"id"`
+	TransactionID string `json:"transactionId"`
+	ContentType   string `json:"contentType"`
+	Urls          Asset  `json:"urls"`
+	CreatedAt     *Time  `json:"createdAt"`
+}
+
+type ReceiptAttachmentResponse struct {
+	ReceiptAttachment ReceiptAttachment `json:"receiptAttachment"`
+}
+
+type ListReceiptAttachmentsResponse struct {
+	ReceiptAttachments []ReceiptAttachment `json:"receiptAttachments"`
+}
+
+// UploadReceipt attaches a receipt read from r to a transaction. contentType
+// is one of application/pdf, image/jpeg, image/png, image/gif or image/heic.
+func (c *Client) UploadReceipt(ctx context.Context, transactionID string, r io.Reader, contentType string) (*ReceiptAttachment, error) {
+	if transactionID == "" {
+		return nil, validationError("transaction ID is required")
+	}
+	extension, ok := receiptExtensions[contentType]
+	if !ok {
+		return nil, validationError("unsupported receipt content type %q", contentType)
+	}
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	err := form.WriteField("transactionId", transactionID)
+	if err != nil {
+		return nil, err
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="receipt%s"`, extension))
+	h.Set("Content-Type", contentType)
+	file, err := form.CreatePart(h)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return nil, fmt.Errorf("read receipt: %w", err)
+	}
+	if size == 0 {
+		return nil, validationError("receipt is empty")
+	}
+	form.Close()
+
+	var response ReceiptAttachmentResponse
+	err = c.request(ctx, &Request{
+		Operation:   "UploadReceipt",
+		Method:      http.MethodPost,
+		Path:        "/receiptattachments",
+		ResourceID:  transactionID,
+		ContentType: form.FormDataContentType(),
+		Body:        body.Bytes(),
+		synthetic: func(ctx context.Context) (any, error) {
+			return ReceiptAttachmentResponse{ReceiptAttachment: ReceiptAttachment{
+				ID:            dryRunID(),
+				TransactionID: transactionID,
+				ContentType:   contentType,
+				CreatedAt:     dryRunTime(time.Now()),
+			}}, nil
+		},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ReceiptAttachment, nil
+}
+
+// ListReceipts returns the receipts attached to a transaction
+func (c *Client) ListReceipts(ctx context.Context, transactionID string) ([]ReceiptAttachment, error) {
+	var response ListReceiptAttachmentsResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "ListReceipts",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/transactions/%s/receiptattachments", transactionID),
+		ResourceID: transactionID,
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.ReceiptAttachments, nil
+}
+
+// DownloadReceipt returns the content of a receipt and its content type
+func (c *Client) DownloadReceipt(ctx context.Context, receiptID string) ([]byte, string, error) {
+	var content rawBody
+	err := c.request(ctx, &Request{
+		Operation:  "DownloadReceipt",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/receiptattachments/%s/content", receiptID),
+		ResourceID: receiptID,
+	}, &content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return content.data, content.contentType, nil
+}
+
+func (c *Client) DeleteReceipt(ctx context.Context, receiptID string) error {
+	return c.jsonRequest(ctx, &Request{
+		Operation:  "DeleteReceipt",
+		Method:     http.MethodDelete,
+		Path:       fmt.Sprintf("/receiptattachments/%s", receiptID),
+		ResourceID: receiptID,
+	}, nil, nil)
+}
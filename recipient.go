@@ -0,0 +1,225 @@
+package extend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+)
+
+type RecipientStatus string
+
+const (
+	// RecipientStatusActive is a member of the organization
+	RecipientStatusActive RecipientStatus = "ACTIVE"
+	// RecipientStatusInvited was invited and hasn't signed up yet
+	RecipientStatusInvited RecipientStatus = "INVITED"
+	// RecipientStatusNew has no account, sending it a card invites it
+	RecipientStatusNew RecipientStatus = "NEW"
+	// RecipientStatusInvalid can't receive cards, Reason says why
+	RecipientStatusInvalid RecipientStatus = "INVALID"
+)
+
+// RecipientValidation tells whether cards can be sent to an email
+type RecipientValidation struct {
+	Email  string          `json:"email"`
+	Status RecipientStatus `json:"status"`
+	Reason string          `json:"reason,omitempty"`
+
+	// User is the account of the email, unless the status is new or invalid
+	User *User `json:"user,omitempty"`
+}
+
+// Valid reports whether cards can be sent to the email
+func (v RecipientValidation) Valid() bool {
+	return v.Status != RecipientStatusInvalid
+}
+
+type RecipientValidationResponse struct {
+	Recipient RecipientValidation `json:"recipient"`
+}
+
+// ValidateRecipient checks an email before it's used as the recipient of a
+// card, rather than finding it in the InvalidEmails of a bulk upload
+func (c *Client) ValidateRecipient(ctx context.Context, email string) (*RecipientValidation, error) {
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, validationError("invalid recipient %q", email)
+	}
+
+	var response RecipientValidationResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation: "ValidateRecipient",
+		Method:    http.MethodGet,
+		Path:      "/recipients/validate?" + url.Values{"email": {email}}.Encode(),
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.Recipient, nil
+}
+
+type InviteRecipientOptions struct {
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+func (o InviteRecipientOptions) validate() error {
+	if _, err := mail.ParseAddress(o.Email); err != nil {
+		return validationError("invalid recipient %q", o.Email)
+	}
+	return nil
+}
+
+// InviteRecipient invites someone to sign up to receive cards, it fails with
+// ErrConflict when the email already has an account
+func (c *Client) InviteRecipient(ctx context.Context, options InviteRecipientOptions) (*User, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var response UserResponse
+	err = c.jsonRequest(ctx, &Request{
+		Operation: "InviteRecipient",
+		Method:    http.MethodPost,
+		Path:      "/recipients",
+		synthetic: func(ctx context.Context) (any, error) {
+			return UserResponse{User: User{
+				ID:        dryRunID(),
+				FirstName: options.FirstName,
+				LastName:  options.LastName,
+				Email:     options.Email,
+			}}, nil
+		},
+	}, options, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.User, nil
+}
+
+// ResendVirtualCardInvite sends the invitation to claim a card to its
+// recipient again
+func (c *Client) ResendVirtualCardInvite(ctx context.Context, id string) error {
+	return c.jsonRequest(ctx, &Request{
+		Operation:  "ResendVirtualCardInvite",
+		Method:     http.MethodPost,
+		Path:       fmt.Sprintf("/virtualcards/%s/resendinvite", id),
+		ResourceID: id,
+	}, nil, nil)
+}
+
+// ReassignVirtualCardOptions are the new people of a card, the ones that
+// aren't set are kept
+type ReassignVirtualCardOptions struct {
+	// Recipient is the email of the recipient
+	Recipient    string `json:"recipient,omitempty"`
+	CardholderID string `json:"cardholderId,omitempty"`
+}
+
+func (o ReassignVirtualCardOptions) validate() error {
+	if o.Recipient == "" && o.CardholderID == "" {
+		return validationError("recipient or cardholder is required")
+	}
+	if o.Recipient != "" {
+		if _, err := mail.ParseAddress(o.Recipient); err != nil {
+			return validationError("invalid recipient %q", o.Recipient)
+		}
+	}
+	return nil
+}
+
+// ReassignVirtualCard changes the recipient or cardholder of a card, the card
+// keeps its number and balance
+func (c *Client) ReassignVirtualCard(ctx context.Context, id string, options ReassignVirtualCardOptions) (*VirtualCard, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var response VirtualCardResponse
+	err = c.jsonRequest(ctx, &Request{
+		Operation:  "ReassignVirtualCard",
+		Method:     http.MethodPut,
+		Path:       fmt.Sprintf("/virtualcards/%s/reassign", id),
+		ResourceID: id,
+		synthetic: c.syntheticCard(id, func(card *VirtualCard) {
+			if options.Recipient != "" {
+				card.RecipientID = ""
+				card.Recipient = User{Email: options.Recipient}
+			}
+			if options.CardholderID != "" {
+				card.CardholderID = options.CardholderID
+				card.Cardholder = User{ID: options.CardholderID}
+			}
+		}),
+	}, options, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.VirtualCard, nil
+}
+
+// TransferVirtualCards moves the active cards of a user to another one, for
+// instance when they leave the team, without closing or reissuing them. The
+// cards they receive get the email of to as recipient and the cards they hold
+// get to as cardholder. Every card is attempted: the cards moved are returned
+// along with the failures joined in the error.
+func (c *Client) TransferVirtualCards(ctx context.Context, fromUserID string, to User) ([]VirtualCard, error) {
+	switch {
+	case fromUserID == "":
+		return nil, validationError("user to transfer cards from is required")
+	case to.ID == "" || to.Email == "":
+		return nil, validationError("ID and email of the user to transfer cards to are required")
+	case to.ID == fromUserID:
+		return nil, validationError("cannot transfer cards of user %s to itself", fromUserID)
+	}
+
+	// Collect the cards first so that reassigning them doesn't shift the
+	// pages
+	var cards []VirtualCard
+	list := c.ListVirtualCards(&ListVirtualCardsOptions{
+		PaginationOptions:  PaginationOptions{Count: 100},
+		CardholderOrViewer: fromUserID,
+		Issued:             true,
+		Statuses:           []VirtualCardStatus{VirtualCardStatusActive},
+	})
+	for list.Next() {
+		page, err := list.Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list virtual cards of user %s: %w", fromUserID, err)
+		}
+		cards = append(cards, page.Items()...)
+	}
+
+	var transferred []VirtualCard
+	var errs []error
+	for _, card := range cards {
+		var options ReassignVirtualCardOptions
+		if card.RecipientID == fromUserID {
+			options.Recipient = to.Email
+		}
+		if card.CardholderID == fromUserID {
+			options.CardholderID = to.ID
+		}
+		if options == (ReassignVirtualCardOptions{}) {
+			// Only a viewer of the card
+			continue
+		}
+
+		updated, err := c.ReassignVirtualCard(ctx, card.ID, options)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transfer virtual card %s: %w", card.ID, err))
+			continue
+		}
+		transferred = append(transferred, *updated)
+	}
+
+	return transferred, errors.Join(errs...)
+}
@@ -0,0 +1,181 @@
+package extend
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RecurrencePeriod string
+
+const (
+	RecurrencePeriodDaily   RecurrencePeriod = "DAILY"
+	RecurrencePeriodWeekly  RecurrencePeriod = "WEEKLY"
+	RecurrencePeriodMonthly RecurrencePeriod = "MONTHLY"
+	RecurrencePeriodYearly  RecurrencePeriod = "YEARLY"
+)
+
+// RecurrenceTerminator ends a recurrence
+type RecurrenceTerminator string
+
+const (
+	// RecurrenceTerminatorNone recurs until the card is closed
+	RecurrenceTerminatorNone RecurrenceTerminator = "NONE"
+
+	// RecurrenceTerminatorCount recurs Count times
+	RecurrenceTerminatorCount RecurrenceTerminator = "COUNT"
+
+	// RecurrenceTerminatorDate recurs until the Until date
+	RecurrenceTerminatorDate RecurrenceTerminator = "DATE"
+)
+
+// Recurrence resets the balance of a card on a schedule, e.g. every month on
+// the 1st for a subscription
+type Recurrence struct {
+	ID string
+
+	// BalanceCents is the balance the card is reset to
+	BalanceCents int
+
+	Period RecurrencePeriod
+
+	// Interval is the number of periods between resets, 1 when zero
+	Interval int
+
+	// DayOfPeriod is the day the balance is reset: a time.Weekday for weekly
+	// recurrences, the day of the month for monthly ones and the day of the
+	// year for yearly ones. It is ignored for daily recurrences.
+	DayOfPeriod int
+
+	Terminator RecurrenceTerminator
+
+	// Count is the number of resets for RecurrenceTerminatorCount
+	Count int
+
+	// Until is the date of the last reset for RecurrenceTerminatorDate
+	// (date only)
+	Until time.Time
+
+	// CurrentCount is the number of resets so far, set by Extend
+	CurrentCount int
+
+	// NextRecurrence is the date of the next reset, set by Extend
+	NextRecurrence *Time
+}
+
+type recurrenceJSON struct {
+	ID             string               `json:"id,omitempty"`
+	BalanceCents   int                  `json:"balanceCents"`
+	Period         RecurrencePeriod     `json:"period"`
+	Interval       int                  `json:"interval"`
+	ByWeekDay      *int                 `json:"byWeekDay,omitempty"`
+	ByMonthDay     *int                 `json:"byMonthDay,omitempty"`
+	ByYearDay      *int                 `json:"byYearDay,omitempty"`
+	Terminator     RecurrenceTerminator `json:"terminator"`
+	Count          int                  `json:"count,omitempty"`
+	Until          string               `json:"until,omitempty"`
+	CurrentCount   int                  `json:"currentCount,omitempty"`
+	NextRecurrence *Time                `json:"nextRecurrence,omitempty"`
+}
+
+func (r Recurrence) MarshalJSON() ([]byte, error) {
+	v := recurrenceJSON{
+		ID:             r.ID,
+		BalanceCents:   r.BalanceCents,
+		Period:         r.Period,
+		Interval:       max(r.Interval, 1),
+		Terminator:     r.Terminator,
+		Count:          r.Count,
+		CurrentCount:   r.CurrentCount,
+		NextRecurrence: r.NextRecurrence,
+	}
+	if v.Terminator == "" {
+		v.Terminator = RecurrenceTerminatorNone
+	}
+	if !r.Until.IsZero() {
+		v.Until = r.Until.Format(dateLayout)
+	}
+	day := r.DayOfPeriod
+	switch r.Period {
+	case RecurrencePeriodWeekly:
+		v.ByWeekDay = &day
+	case RecurrencePeriodMonthly:
+		v.ByMonthDay = &day
+	case RecurrencePeriodYearly:
+		v.ByYearDay = &day
+	}
+	return json.Marshal(v)
+}
+
+func (r *Recurrence) UnmarshalJSON(data []byte) error {
+	var v recurrenceJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*r = Recurrence{
+		ID:             v.ID,
+		BalanceCents:   v.BalanceCents,
+		Period:         v.Period,
+		Interval:       v.Interval,
+		Terminator:     v.Terminator,
+		Count:          v.Count,
+		CurrentCount:   v.CurrentCount,
+		NextRecurrence: v.NextRecurrence,
+	}
+	if v.Until != "" {
+		until, err := time.Parse(dateLayout, v.Until)
+		if err != nil {
+			return err
+		}
+		r.Until = until
+	}
+	for _, day := range []*int{v.ByWeekDay, v.ByMonthDay, v.ByYearDay} {
+		if day != nil {
+			r.DayOfPeriod = *day
+		}
+	}
+	return nil
+}
+
+func (r Recurrence) validate() error {
+	switch r.Period {
+	case RecurrencePeriodDaily:
+	case RecurrencePeriodWeekly:
+		if r.DayOfPeriod < int(time.Sunday) || r.DayOfPeriod > int(time.Saturday) {
+			return validationError("weekly recurrence day must be a weekday between 0 (Sunday) and 6 (Saturday), got %d", r.DayOfPeriod)
+		}
+	case RecurrencePeriodMonthly:
+		if r.DayOfPeriod < 1 || r.DayOfPeriod > 31 {
+			return validationError("monthly recurrence day must be between 1 and 31, got %d", r.DayOfPeriod)
+		}
+	case RecurrencePeriodYearly:
+		if r.DayOfPeriod < 1 || r.DayOfPeriod > 366 {
+			return validationError("yearly recurrence day must be between 1 and 366, got %d", r.DayOfPeriod)
+		}
+	default:
+		return validationError("invalid recurrence period %q", r.Period)
+	}
+
+	switch {
+	case r.Interval < 0:
+		return validationError("recurrence interval must be positive, got %d", r.Interval)
+	case r.BalanceCents <= 0:
+		return validationError("recurrence balance must be positive, got %d cents", r.BalanceCents)
+	}
+
+	switch r.Terminator {
+	case "", RecurrenceTerminatorNone:
+	case RecurrenceTerminatorCount:
+		if r.Count <= 0 {
+			return validationError("recurrence count must be positive, got %d", r.Count)
+		}
+	case RecurrenceTerminatorDate:
+		if r.Until.IsZero() {
+			return validationError("recurrence until date is required")
+		}
+	default:
+		return validationError("invalid recurrence terminator %q", r.Terminator)
+	}
+
+	return nil
+}
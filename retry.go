@@ -0,0 +1,156 @@
+package extend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries
+	MaxAttempts int
+
+	// InitialBackoff is the base delay before the first retry, doubled on
+	// every following attempt
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the backoff between two attempts. A server asking
+	// through Retry-After to wait longer isn't retried, the error is
+	// returned instead.
+	MaxBackoff time.Duration
+}
+
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+	}
+
+	// NoRetries makes every request a single attempt
+	NoRetries = RetryPolicy{MaxAttempts: 1}
+)
+
+// backoff returns the delay before the given retry (1 for the first retry)
+// using exponential backoff with full jitter. A server supplied Retry-After
+// takes precedence when it is longer, callers check it against MaxBackoff
+// with waitsTooLong first.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+
+	return max(delay, retryAfter)
+}
+
+// waitsTooLong reports whether the server asked to wait longer than the
+// policy allows between two attempts
+func (p RetryPolicy) waitsTooLong(retryAfter time.Duration) bool {
+	return p.MaxBackoff > 0 && retryAfter > p.MaxBackoff
+}
+
+// idempotent reports whether repeating a request has the same effect as
+// sending it once. PUT requests replace state on the Extend API (update,
+// cancel, close) so they are safe to replay unless marked NonIdempotent,
+// POST requests create resources and are not.
+func (r *Request) idempotent() bool {
+	if r.NonIdempotent {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetryStatus reports whether a response status is worth retrying.
+// 429 means the request was rejected before being processed, so it is retried
+// for every request. Gateway errors may happen after the request reached
+// Extend and are only retried for idempotent requests.
+func shouldRetryStatus(req *Request, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.idempotent()
+	}
+	return false
+}
+
+// shouldRetryError reports whether a transport error is worth retrying.
+// Failures to connect are always retried since nothing reached the server.
+// Resets, unexpected EOFs and timeouts may happen after the server received
+// the request and are only retried for idempotent requests.
+func shouldRetryError(ctx context.Context, req *Request, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	if !req.idempotent() {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns zero when the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package extend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShouldRetryStatus(t *testing.T) {
+	tests := []struct {
+		method        string
+		nonIdempotent bool
+		status        int
+		want          bool
+	}{
+		{http.MethodGet, false, http.StatusTooManyRequests, true},
+		{http.MethodPost, false, http.StatusTooManyRequests, true},
+		{http.MethodPut, true, http.StatusTooManyRequests, true},
+		{http.MethodGet, false, http.StatusBadGateway, true},
+		{http.MethodPut, false, http.StatusServiceUnavailable, true},
+		{http.MethodDelete, false, http.StatusGatewayTimeout, true},
+		{http.MethodPost, false, http.StatusBadGateway, false},
+		{http.MethodPut, true, http.StatusBadGateway, false},
+		{http.MethodGet, false, http.StatusInternalServerError, false},
+		{http.MethodGet, false, http.StatusNotFound, false},
+		{http.MethodGet, false, http.StatusUnauthorized, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %d non-idempotent=%t", test.method, test.status, test.nonIdempotent), func(t *testing.T) {
+			req := &Request{Method: test.method, NonIdempotent: test.nonIdempotent}
+			if got := shouldRetryStatus(req, test.status); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestShouldRetryError(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		err    error
+		want   bool
+	}{
+		{"dial GET", context.Background(), http.MethodGet, dial, true},
+		{"dial POST", context.Background(), http.MethodPost, dial, true},
+		{"reset GET", context.Background(), http.MethodGet, reset, true},
+		{"reset POST", context.Background(), http.MethodPost, reset, false},
+		{"timeout PUT", context.Background(), http.MethodPut, timeoutError{}, true},
+		{"timeout POST", context.Background(), http.MethodPost, timeoutError{}, false},
+		{"unexpected EOF", context.Background(), http.MethodGet, io.ErrUnexpectedEOF, true},
+		{"other error", context.Background(), http.MethodGet, errors.New("boom"), false},
+		{"cancelled", cancelled, http.MethodGet, dial, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := shouldRetryError(test.ctx, &Request{Method: test.method}, test.err); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}
+
+	tests := []struct {
+		name       string
+		retry      int
+		retryAfter time.Duration
+		// the delay is drawn from [min, max]
+		min, max time.Duration
+	}{
+		{"first retry", 1, 0, 0, time.Second},
+		{"third retry", 3, 0, 0, 4 * time.Second},
+		{"capped", 10, 0, 0, 4 * time.Second},
+		{"retry after", 1, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+		{"retry after over the cap", 1, 2 * time.Minute, 2 * time.Minute, 2 * time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if got := policy.backoff(test.retry, test.retryAfter); got < test.min || got > test.max {
+					t.Fatalf("got %s, want between %s and %s", got, test.min, test.max)
+				}
+			}
+		})
+	}
+
+	if !policy.waitsTooLong(5*time.Second) || policy.waitsTooLong(4*time.Second) {
+		t.Error("waitsTooLong doesn't compare Retry-After with MaxBackoff")
+	}
+	if (RetryPolicy{}).waitsTooLong(time.Hour) {
+		t.Error("waitsTooLong without MaxBackoff")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			if got := parseRetryAfter(test.value); got < test.min || got > test.max {
+				t.Errorf("got %s, want between %s and %s", got, test.min, test.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package extend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrRevealDisabled = errors.New("extend: revealing virtual cards is disabled")
+
+// RevealedCard is the sensitive data of a virtual card, only returned by
+// RevealVirtualCard. The number and security code are redacted when the
+// value is logged or formatted.
+type RevealedCard struct {
+	VirtualCardID string
+	Number        string
+	SecurityCode  string
+	Expires       time.Time
+}
+
+// RevealEvent is passed to the audit hook for every attempt to reveal a card,
+// Err is set when it failed
+type RevealEvent struct {
+	VirtualCardID string
+	Time          time.Time
+	Err           error
+}
+
+// WithRevealAudit calls audit for every attempt to reveal a card, e.g. to
+// record who accessed a card number
+func WithRevealAudit(audit func(ctx context.Context, event RevealEvent)) Option {
+	return func(c *Client) {
+		c.revealAudit = audit
+	}
+}
+
+// WithRevealDisabled makes RevealVirtualCard fail with ErrRevealDisabled, for
+// clients that never need card numbers
+func WithRevealDisabled() Option {
+	return func(c *Client) {
+		c.revealDisabled = true
+	}
+}
+
+type revealResponse struct {
+	VirtualCard struct {
+		ID           string `json:"id"`
+		Vcn          string `json:"vcn"`
+		SecurityCode string `json:"securityCode"`
+		Expires      *Time  `json:"expires"`
+	} `json:"virtualCard"`
+}
+
+// RevealVirtualCard returns the number, security code and expiry of a card
+func (c *Client) RevealVirtualCard(ctx context.Context, id string) (_ *RevealedCard, err error) {
+	defer func() {
+		c.log(ctx, slog.LevelInfo, "virtual card reveal",
+			slog.String("card_id", id),
+			slog.Bool("ok", err == nil),
+		)
+		if c.revealAudit != nil {
+			c.revealAudit(ctx, RevealEvent{VirtualCardID: id, Time: time.Now(), Err: err})
+		}
+	}()
+
+	if c.revealDisabled {
+		return nil, ErrRevealDisabled
+	}
+
+	var response revealResponse
+	err = c.jsonRequest(ctx, &Request{
+		Operation:  "RevealVirtualCard",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/virtualcards/%s", id),
+		ResourceID: id,
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	card := response.VirtualCard
+	if card.Vcn == "" || card.SecurityCode == "" {
+		return nil, fmt.Errorf("extend: virtual card %s returned no card number", id)
+	}
+
+	revealed := &RevealedCard{
+		VirtualCardID: id,
+		Number:        card.Vcn,
+		SecurityCode:  card.SecurityCode,
+	}
+	if card.Expires != nil {
+		revealed.Expires = card.Expires.Time
+	}
+	return revealed, nil
+}
+
+// cardSecretFields are the JSON fields holding card numbers and security
+// codes, compared ignoring case
+var cardSecretFields = []string{"vcn", "securityCode"}
+
+// scrubCardSecrets replaces the card numbers and security codes of a JSON
+// body with Redacted, so the body passed back to middleware never holds
+// them. Reading a card returns its number even when it isn't revealed.
+func scrubCardSecrets(body []byte) []byte {
+	lower := bytes.ToLower(body)
+	found := false
+	for _, field := range cardSecretFields {
+		if bytes.Contains(lower, []byte(`"`+strings.ToLower(field)+`"`)) {
+			found = true
+		}
+	}
+	if !found {
+		return body
+	}
+
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		// Not JSON, keep nothing rather than risk leaking a number
+		return nil
+	}
+	scrubbed, err := json.Marshal(scrubValue(value))
+	if err != nil {
+		return nil
+	}
+	return scrubbed
+}
+
+func scrubValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if isCardSecretField(key) {
+				if field != nil {
+					v[key] = Redacted
+				}
+				continue
+			}
+			v[key] = scrubValue(field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = scrubValue(item)
+		}
+	}
+	return value
+}
+
+func isCardSecretField(key string) bool {
+	for _, field := range cardSecretFields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogValue keeps the card number and security code out of structured logs
+func (r RevealedCard) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("virtual_card_id", r.VirtualCardID),
+		slog.String("number", redact(r.Number)),
+		slog.String("security_code", redact(r.SecurityCode)),
+		slog.String("expires", r.Expires.Format("01/2006")),
+	)
+}
+
+// String keeps the card number and security code out of values printed
+// with fmt verbs such as %v and %+v
+func (r RevealedCard) String() string {
+	return fmt.Sprintf("{VirtualCardID:%s Number:%s SecurityCode:%s Expires:%s}",
+		r.VirtualCardID, redact(r.Number), redact(r.SecurityCode), r.Expires.Format("01/2006"))
+}
+
+// GoString applies the same redaction as String to %#v
+func (r RevealedCard) GoString() string {
+	return "extend.RevealedCard" + r.String()
+}
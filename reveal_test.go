@@ -0,0 +1,74 @@
+package extend_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestResponseBodyHasNoCardNumber(t *testing.T) {
+	server := extendtest.NewServer()
+	defer server.Close()
+	seeded := server.SeedCard(extend.VirtualCard{
+		DisplayName:  "Travel",
+		CreditCardID: "cc_1",
+		BalanceCents: 1000,
+		ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+	})
+	number, securityCode, _ := server.CardNumber(seeded.ID)
+
+	bodies := map[string][]byte{}
+	client := server.Client(extend.WithMiddleware(func(next extend.Handler) extend.Handler {
+		return func(ctx context.Context, req *extend.Request) (*extend.Response, error) {
+			res, err := next(ctx, req)
+			if res != nil {
+				bodies[req.Operation] = res.Body
+			}
+			return res, err
+		}
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		operation string
+		call      func() error
+	}{
+		{"GetVirtualCard", func() error {
+			_, err := client.GetVirtualCard(ctx, seeded.ID)
+			return err
+		}},
+		{"UpdateVirtualCard", func() error {
+			_, err := client.UpdateVirtualCard(ctx, seeded.ID, extend.UpdateVirtualCardOptions{DisplayName: extend.Ptr("Trips")})
+			return err
+		}},
+		{"RevealVirtualCard", func() error {
+			revealed, err := client.RevealVirtualCard(ctx, seeded.ID)
+			if err == nil && (revealed.Number != number || revealed.SecurityCode != securityCode) {
+				t.Errorf("revealed %s/%s, want %s/%s", revealed.Number, revealed.SecurityCode, number, securityCode)
+			}
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.operation, func(t *testing.T) {
+			if err := test.call(); err != nil {
+				t.Fatal(err)
+			}
+			body, ok := bodies[test.operation]
+			if !ok {
+				t.Fatalf("middleware didn't see %s", test.operation)
+			}
+			if bytes.Contains(body, []byte(number)) || bytes.Contains(body, []byte(`"securityCode":"`+securityCode+`"`)) {
+				t.Errorf("body of %s holds the card number: %s", test.operation, body)
+			}
+			if !bytes.Contains(body, []byte(seeded.ID)) {
+				t.Errorf("body of %s lost the card: %s", test.operation, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package extend
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "local/extend"
+
+// WithTracerProvider makes the client create a span for every call using
+// provider. By default the global provider is used, which does nothing
+// unless the application registers one with otel.SetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracerProvider = provider
+	}
+}
+
+func (c *Client) tracer() trace.Tracer {
+	provider := c.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+func (c *Client) startSpan(ctx context.Context, req *Request) (context.Context, trace.Span) {
+	path, _, _ := strings.Cut(req.Path, "?")
+	attrs := []attribute.KeyValue{
+		attribute.String("extend.operation", req.Operation),
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.path", path),
+	}
+	if req.ResourceID != "" {
+		attrs = append(attrs, attribute.String("extend.resource_id", req.ResourceID))
+	}
+
+	return c.tracer().Start(ctx, "extend."+req.Operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func endSpan(span trace.Span, res *Response, err error) {
+	if res != nil {
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", res.StatusCode),
+			attribute.Int("extend.attempts", res.Attempts),
+		)
+	}
+
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			span.SetAttributes(attribute.Int("http.response.status_code", apiErr.StatusCode))
+			if apiErr.RequestID != "" {
+				span.SetAttributes(attribute.String("extend.request_id", apiErr.RequestID))
+			}
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,164 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type TransactionStatus string
+
+const (
+	TransactionStatusPending      TransactionStatus = "PENDING"
+	TransactionStatusCleared      TransactionStatus = "CLEARED"
+	TransactionStatusDeclined     TransactionStatus = "DECLINED"
+	TransactionStatusReversed     TransactionStatus = "AUTH_REVERSAL"
+	TransactionStatusNoMatch      TransactionStatus = "NO_MATCH"
+	TransactionStatusAVSPassed    TransactionStatus = "AVS_PASS"
+	TransactionStatusAVSFailed    TransactionStatus = "AVS_FAIL"
+	TransactionStatusAuthRejected TransactionStatus = "AUTH_REJECT"
+)
+
+type TransactionType string
+
+const (
+	TransactionTypeDebit  TransactionType = "DEBIT"
+	TransactionTypeCredit TransactionType = "CREDIT"
+)
+
+type Transaction struct {
+	ID     string            `json:"id"`
+	Status TransactionStatus `json:"status"`
+	Type   TransactionType   `json:"type"`
+
+	CardholderID    string `json:"cardholderId"`
+	CardholderName  string `json:"cardholderName"`
+	CardholderEmail string `json:"cardholderEmail"`
+	RecipientID     string `json:"recipientId"`
+	RecipientName   string `json:"recipientName"`
+	RecipientEmail  string `json:"recipientEmail"`
+
+	VirtualCardID          string `json:"vcnId"`
+	VirtualCardDisplayName string `json:"vcnDisplayName"`
+	VirtualCardLast4       string `json:"vcnLast4"`
+	CreditCardID           string `json:"creditCardId"`
+
+	MerchantID      string `json:"merchantId"`
+	MerchantName    string `json:"merchantName"`
+	MerchantCity    string `json:"merchantCity"`
+	MerchantState   string `json:"merchantState"`
+	MerchantCountry string `json:"merchantCountry"`
+
+	MCC            string `json:"mcc"`
+	MCCGroup       string `json:"mccGroup"`
+	MCCDescription string `json:"mccDescription"`
+
+	// Authorized amounts are held when the card is used, settled amounts are
+	// charged when the merchant clears the transaction. Billing amounts are
+	// in the card currency, merchant amounts in the currency of the merchant.
+	AuthBillingAmountCents      int     `json:"authBillingAmountCents"`
+	AuthBillingCurrency         string  `json:"authBillingCurrency"`
+	AuthMerchantAmountCents     int     `json:"authMerchantAmountCents"`
+	AuthMerchantCurrency        string  `json:"authMerchantCurrency"`
+	AuthExchangeRate            float64 `json:"authExchangeRate"`
+	ClearingBillingAmountCents  int     `json:"clearingBillingAmountCents"`
+	ClearingBillingCurrency     string  `json:"clearingBillingCurrency"`
+	ClearingMerchantAmountCents int     `json:"clearingMerchantAmountCents"`
+	ClearingMerchantCurrency    string  `json:"clearingMerchantCurrency"`
+	ClearingExchangeRate        float64 `json:"clearingExchangeRate"`
+
+	ApprovalCode  string `json:"approvalCode"`
+	DeclineReason string `json:"declineReason"`
+
+	ReceiptAttachmentIDs []string `json:"receiptAttachmentIds"`
+	ReceiptRequired      bool     `json:"receiptRequired"`
+
+	AuthedAt   *Time `json:"authedAt"`
+	ClearedAt  *Time `json:"clearedAt"`
+	DeclinedAt *Time `json:"declinedAt"`
+	UpdatedAt  *Time `json:"updatedAt"`
+}
+
+// AmountCents is the settled amount of a cleared transaction, the authorized
+// amount otherwise, in the card currency
+func (t Transaction) AmountCents() int {
+	if t.Status == TransactionStatusCleared {
+		return t.ClearingBillingAmountCents
+	}
+	return t.AuthBillingAmountCents
+}
+
+type TransactionResponse struct {
+	Transaction Transaction `json:"transaction"`
+}
+
+func (c *Client) GetTransaction(ctx context.Context, id string) (*Transaction, error) {
+	var response TransactionResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "GetTransaction",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/transactions/%s", id),
+		ResourceID: id,
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.Transaction, nil
+}
+
+// ListTransactionsOptions filters transactions, zero values don't filter
+type ListTransactionsOptions struct {
+	PaginationOptions
+	VirtualCardID string
+
+	// Since and Until bound the time the transactions were authorized
+	Since time.Time
+	Until time.Time
+
+	Statuses []TransactionStatus
+
+	MinAmountCents *int
+	MaxAmountCents *int
+
+	// Merchant matches the merchant name
+	Merchant string
+}
+
+type ListTransactionsResponse struct {
+	PaginationResponse
+	Transactions []Transaction `json:"transactions"`
+}
+
+func (r ListTransactionsResponse) Items() []Transaction {
+	return r.Transactions
+}
+
+func (c *Client) ListTransactions(options *ListTransactionsOptions) *Paginator[Transaction, ListTransactionsResponse] {
+	query := url.Values{}
+	if options.VirtualCardID != "" {
+		query.Set("virtualCardId", options.VirtualCardID)
+	}
+	if !options.Since.IsZero() {
+		query.Set("since", options.Since.UTC().Format(time.RFC3339))
+	}
+	if !options.Until.IsZero() {
+		query.Set("until", options.Until.UTC().Format(time.RFC3339))
+	}
+	if len(options.Statuses) > 0 {
+		query.Set("statuses", join(options.Statuses, ","))
+	}
+	if options.MinAmountCents != nil {
+		query.Set("minAmountCents", strconv.Itoa(*options.MinAmountCents))
+	}
+	if options.MaxAmountCents != nil {
+		query.Set("maxAmountCents", strconv.Itoa(*options.MaxAmountCents))
+	}
+	if options.Merchant != "" {
+		query.Set("search", options.Merchant)
+	}
+	return newPaginator[Transaction, ListTransactionsResponse](c, "ListTransactions", options.PaginationOptions, "/transactions", query)
+}
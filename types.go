@@ -76,6 +76,29 @@ func (t *Time) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// dateLayout formats the date-only fields of requests
+const dateLayout = "2006-01-02"
+
+// cardLocation is the location of a card timezone, UTC when unknown
+func cardLocation(timezone string) *time.Location {
+	location, err := time.LoadLocation(timezone)
+	if timezone == "" || err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// cardDate formats the date of t in the timezone of a card
+func cardDate(t time.Time, timezone string) string {
+	return t.In(cardLocation(timezone)).Format(dateLayout)
+}
+
+// cardMidnight is the start of the calendar date of date in the timezone of
+// a card
+func cardMidnight(date time.Time, timezone string) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, cardLocation(timezone))
+}
+
 func join[T ~string](values []T, sep string) string {
 	v := ""
 	for i, value := range values {
@@ -87,21 +110,7 @@ func join[T ~string](values []T, sep string) string {
 	return v
 }
 
-type apiErrorDetail struct {
-	Field        string `json:"field"`
-	Error        string `json:"error"`
-	InvalidValue string `json:"invalidValue"`
-}
-
-type apiErrorResponse struct {
-	ErrorMessage string           `json:"error"`
-	Details      []apiErrorDetail `json:"details"`
-}
-
-func (e apiErrorResponse) Error() string {
-	message := "extend: " + e.ErrorMessage
-	for _, detail := range e.Details {
-		message = fmt.Sprintf("%s (%s: %s)", message, detail.Field, detail.Error)
-	}
-	return message
+// Ptr returns a pointer to v, to set optional fields inline
+func Ptr[T any](v T) *T {
+	return &v
 }
@@ -0,0 +1,92 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Name is the full name of the user
+func (u User) Name() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+type UserResponse struct {
+	User User `json:"user"`
+}
+
+// GetCurrentUser returns the user the client is logged in as, with its
+// organization and role
+func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
+	var response UserResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation: "GetCurrentUser",
+		Method:    http.MethodGet,
+		Path:      "/me",
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.User, nil
+}
+
+type ListOrganizationMembersOptions struct {
+	PaginationOptions
+	OrganizationID string
+
+	// Search matches the name or email of members
+	Search string
+}
+
+type ListOrganizationMembersResponse struct {
+	PaginationResponse
+	Users []User `json:"users"`
+}
+
+func (r ListOrganizationMembersResponse) Items() []User {
+	return r.Users
+}
+
+func (c *Client) ListOrganizationMembers(options *ListOrganizationMembersOptions) *Paginator[User, ListOrganizationMembersResponse] {
+	query := url.Values{}
+	if options.Search != "" {
+		query.Set("search", options.Search)
+	}
+	path := fmt.Sprintf("/organizations/%s/users", url.PathEscape(options.OrganizationID))
+	return newPaginator[User, ListOrganizationMembersResponse](c, "ListOrganizationMembers", options.PaginationOptions, path, query)
+}
+
+// FindOrganizationMember returns the member of an organization whose full
+// name or email is query, ignoring case. It fails with ErrNotFound when there
+// is none and with ErrConflict when several members match.
+func (c *Client) FindOrganizationMember(ctx context.Context, organizationID, query string) (*User, error) {
+	var found []User
+	members := c.ListOrganizationMembers(&ListOrganizationMembersOptions{
+		PaginationOptions: PaginationOptions{Count: 100},
+		OrganizationID:    organizationID,
+		Search:            query,
+	})
+	for members.Next() {
+		page, err := members.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range page.Items() {
+			if strings.EqualFold(user.Name(), query) || strings.EqualFold(user.Email, query) {
+				found = append(found, user)
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: no member of organization %s matches %q", ErrNotFound, organizationID, query)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %d members of organization %s match %q", ErrConflict, len(found), organizationID, query)
+	}
+}
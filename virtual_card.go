@@ -3,9 +3,12 @@ package extend
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,24 +19,231 @@ type CreateVirtualCardOptions struct {
 	BalanceCents int      `json:"balanceCents"`
 	Currency     Currency `json:"currency"`
 	Notes        string   `json:"notes"`
+	// ValidFrom is the date the card becomes usable (date only), the card
+	// is usable right away when zero
+	ValidFrom time.Time `json:"-"`
 	// ValidTo is the date the card expires (date only)
 	ValidTo time.Time `json:"-"`
+	// Timezone is the IANA timezone the validity dates are interpreted in,
+	// the timezone of the recipient when empty
+	Timezone string `json:"timezone,omitempty"`
 	// Recipient is the email of the recipient
 	Recipient string `json:"recipient"`
+
+	// Recurrence makes the card recurring, its balance is reset on a
+	// schedule
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+
+	// MCCControl restricts the merchant categories the card can be used at
+	MCCControl *MCCControl `json:"mccControl,omitempty"`
+
+	// IdempotencyKey identifies the card across calls: a card is issued at
+	// most once per key, and calls with a key that already issued a card
+	// return that card. When empty, a key is derived from the options so a
+	// call retried after an ambiguous failure doesn't issue a second card.
+	IdempotencyKey string `json:"-"`
 }
 
 type createVirtualCardOptions struct {
 	CreateVirtualCardOptions
-	ValidTo string `json:"validTo"`
+	ValidFrom string `json:"validFrom,omitempty"`
+	ValidTo   string `json:"validTo"`
+	Recurs    bool   `json:"recurs"`
 }
 
+func (o CreateVirtualCardOptions) validate() error {
+	if o.Timezone != "" {
+		if _, err := time.LoadLocation(o.Timezone); err != nil {
+			return validationError("invalid timezone %q", o.Timezone)
+		}
+	}
+	today := time.Now().In(cardLocation(o.Timezone)).Format(dateLayout)
+
+	switch {
+	case o.CreditCardID == "":
+		return validationError("credit card ID is required")
+	case o.DisplayName == "":
+		return validationError("display name is required")
+	case o.BalanceCents <= 0:
+		return validationError("balance must be positive, got %d cents", o.BalanceCents)
+	case o.ValidTo.IsZero():
+		return validationError("valid to date is required")
+	case o.ValidTo.Format(dateLayout) < today:
+		return validationError("valid to date %s is in the past", o.ValidTo.Format(dateLayout))
+	case !o.ValidFrom.IsZero() && o.ValidFrom.Format(dateLayout) < today:
+		return validationError("valid from date %s is in the past", o.ValidFrom.Format(dateLayout))
+	case !o.ValidFrom.IsZero() && o.ValidFrom.Format(dateLayout) > o.ValidTo.Format(dateLayout):
+		return validationError("valid from date %s is after valid to date %s", o.ValidFrom.Format(dateLayout), o.ValidTo.Format(dateLayout))
+	}
+
+	if o.Recipient != "" {
+		if _, err := mail.ParseAddress(o.Recipient); err != nil {
+			return validationError("invalid recipient %q", o.Recipient)
+		}
+	}
+
+	if o.Recurrence != nil {
+		if err := o.Recurrence.validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.MCCControl != nil {
+		return o.MCCControl.validate()
+	}
+
+	return nil
+}
+
+// CreateVirtualCard issues a virtual card. Creations are recorded in the
+// idempotency journal and marked in the card notes, so when a creation fails
+// without a definite answer from Extend, the card is looked up before any
+// other is issued for the same key.
 func (a *Client) CreateVirtualCard(ctx context.Context, options CreateVirtualCardOptions) (*VirtualCard, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.dryRun || a.journal == nil {
+		return a.createVirtualCard(ctx, options)
+	}
+
+	fingerprint := options.fingerprint()
+	key := options.IdempotencyKey
+	derived := key == ""
+	if derived {
+		key = "auto-" + fingerprint[:32]
+	}
+
+	return a.createOnce(ctx, key, !derived, func() (*VirtualCard, error) {
+		return a.createWithJournal(ctx, options, key, fingerprint, derived)
+	})
+}
+
+// createWithJournal issues a card for key unless the journal shows it was
+// already issued or is being issued by another process
+func (a *Client) createWithJournal(ctx context.Context, options CreateVirtualCardOptions, key, fingerprint string, derived bool) (*VirtualCard, error) {
+	entry, err := a.journal.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("read idempotency journal: %w", err)
+	}
+	if entry != nil {
+		if entry.Fingerprint != fingerprint {
+			return nil, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, key)
+		}
+		if entry.CardID != "" {
+			return a.GetVirtualCard(ctx, entry.CardID)
+		}
+
+		// A previous creation with this key failed ambiguously or is still
+		// running in another process
+		card, err := a.findMarkedCard(ctx, *entry)
+		if err != nil {
+			return nil, err
+		}
+		if card != nil {
+			a.log(ctx, slog.LevelWarn, "virtual card already created",
+				slog.String("idempotency_key", key),
+				slog.String("card_id", card.ID),
+			)
+			return card, a.completeCreation(*entry, card.ID, derived)
+		}
+		if entry.inFlight(time.Now()) {
+			return nil, fmt.Errorf("%w: %s started at %s", ErrCreationInProgress, key, entry.StartedAt.Format(time.RFC3339))
+		}
+	} else {
+		entry = newJournalEntry(key, fingerprint, derived)
+	}
+
+	entry.StartedAt = time.Now()
+	entry.EndedAt = time.Time{}
+	if err := a.journal.Put(*entry); err != nil {
+		return nil, fmt.Errorf("write idempotency journal: %w", err)
+	}
+
+	options.Notes = strings.TrimSpace(options.Notes + " " + entry.Marker)
+	card, err := a.createVirtualCard(ctx, options)
+	if err != nil {
+		if !ambiguous(err) {
+			a.journal.Delete(key)
+			return nil, err
+		}
+
+		found, searchErr := a.findMarkedCard(ctx, *entry)
+		if searchErr != nil || found == nil {
+			// Keep the entry, the card may still show up on the next call
+			entry.EndedAt = time.Now()
+			a.journal.Put(*entry)
+			return nil, err
+		}
+		a.log(ctx, slog.LevelWarn, "virtual card created despite failure",
+			slog.String("idempotency_key", key),
+			slog.String("card_id", found.ID),
+			slog.String("error", err.Error()),
+		)
+		card = found
+	}
+
+	return card, a.completeCreation(*entry, card.ID, derived)
+}
+
+// completeCreation records the card issued for an entry. Derived keys are
+// forgotten instead, so identical cards can be created on purpose later.
+func (a *Client) completeCreation(entry JournalEntry, cardID string, derived bool) error {
+	var err error
+	if derived {
+		err = a.journal.Delete(entry.Key)
+	} else {
+		entry.CardID = cardID
+		err = a.journal.Put(entry)
+	}
+	if err != nil {
+		return fmt.Errorf("write idempotency journal: %w", err)
+	}
+	return nil
+}
+
+func (a *Client) createVirtualCard(ctx context.Context, options CreateVirtualCardOptions) (*VirtualCard, error) {
 	payload := createVirtualCardOptions{
 		CreateVirtualCardOptions: options,
-		ValidTo:                  options.ValidTo.Format("2006-01-02"),
+		ValidTo:                  options.ValidTo.Format(dateLayout),
+		Recurs:                   options.Recurrence != nil,
+	}
+	if !options.ValidFrom.IsZero() {
+		payload.ValidFrom = options.ValidFrom.Format(dateLayout)
 	}
 	var response VirtualCardResponse
-	err := a.jsonRequest(ctx, http.MethodPost, "/virtualcards", payload, &response)
+	err := a.jsonRequest(ctx, &Request{
+		Operation: "CreateVirtualCard",
+		Method:    http.MethodPost,
+		Path:      "/virtualcards",
+		synthetic: func(ctx context.Context) (any, error) {
+			now := time.Now()
+			validFrom := now
+			if !options.ValidFrom.IsZero() {
+				validFrom = cardMidnight(options.ValidFrom, options.Timezone)
+			}
+			return VirtualCardResponse{VirtualCard: VirtualCard{
+				ID:           dryRunID(),
+				Status:       VirtualCardStatusActive,
+				DisplayName:  options.DisplayName,
+				Notes:        options.Notes,
+				Currency:     string(options.Currency),
+				LimitCents:   options.BalanceCents,
+				BalanceCents: options.BalanceCents,
+				CreditCardID: options.CreditCardID,
+				Recurs:       options.Recurrence != nil,
+				Recurrence:   options.Recurrence,
+				MCCControl:   options.MCCControl,
+				Timezone:     options.Timezone,
+				ValidFrom:    dryRunTime(validFrom),
+				ValidTo:      dryRunTime(cardMidnight(options.ValidTo, options.Timezone)),
+				CreatedAt:    dryRunTime(now),
+				UpdatedAt:    dryRunTime(now),
+			}}, nil
+		},
+	}, payload, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -41,31 +251,192 @@ func (a *Client) CreateVirtualCard(ctx context.Context, options CreateVirtualCar
 	return &response.VirtualCard, nil
 }
 
+// UpdateVirtualCardOptions changes the fields that are set, the others keep
+// their current value
 type UpdateVirtualCardOptions struct {
-	CreditCardID string `json:"creditCardId"`
-	DisplayName  string `json:"displayName"`
-	BalanceCents int    `json:"balanceCents"`
-	Recurs       bool   `json:"recurs"`
+	CreditCardID *string
+	DisplayName  *string
+
+	// BalanceCents is the new limit of the card, what was already spent
+	// stays spent
+	BalanceCents *int
+
+	// Recurs set to false stops the card from recurring. Setting Recurrence
+	// makes it recurring.
+	Recurs     *bool
+	Recurrence *Recurrence
+
+	// MCCControl replaces the merchant category controls of the card, an
+	// empty MCCControl removes them
+	MCCControl *MCCControl
+
+	// ValidFrom and ValidTo are the dates the card becomes usable and
+	// expires (date only), in the timezone of the card
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+
+	Currency           *Currency
+	ReceiptRulesExempt *bool
+
+	// UpdatedAt is the UpdatedAt of the card the changes are based on. When
+	// set, the update fails with ErrConflict if the card fetched before
+	// sending the changes was updated since. The check is made by the client,
+	// Extend has no precondition on updates, so a change landing between the
+	// fetch and the update is still overwritten.
+	UpdatedAt *Time
+}
 
-	// ValidTo is the date the card expires (date only)
-	ValidTo time.Time `json:"-"`
+func (o UpdateVirtualCardOptions) validate() error {
+	switch {
+	case o.CreditCardID != nil && *o.CreditCardID == "":
+		return validationError("credit card ID must not be empty")
+	case o.DisplayName != nil && *o.DisplayName == "":
+		return validationError("display name must not be empty")
+	case o.BalanceCents != nil && *o.BalanceCents <= 0:
+		return validationError("balance must be positive, got %d cents", *o.BalanceCents)
+	case o.ValidFrom != nil && o.ValidFrom.IsZero():
+		return validationError("valid from date must not be zero")
+	case o.ValidTo != nil && o.ValidTo.IsZero():
+		return validationError("valid to date must not be zero")
+	case o.Recurs != nil && !*o.Recurs && o.Recurrence != nil:
+		return validationError("recurrence is set on a card that stops recurring")
+	}
+
+	if o.Recurrence != nil {
+		if err := o.Recurrence.validate(); err != nil {
+			return err
+		}
+	}
+	if o.MCCControl != nil && !o.MCCControl.empty() {
+		return o.MCCControl.validate()
+	}
+	return nil
+}
+
+// apply sets the changed fields on card
+func (o UpdateVirtualCardOptions) apply(card *VirtualCard) {
+	if o.CreditCardID != nil {
+		card.CreditCardID = *o.CreditCardID
+	}
+	if o.DisplayName != nil {
+		card.DisplayName = *o.DisplayName
+	}
+	if o.BalanceCents != nil {
+		card.BalanceCents += *o.BalanceCents - card.LimitCents
+		card.LimitCents = *o.BalanceCents
+	}
+	if o.Recurs != nil {
+		card.Recurs = *o.Recurs
+		if !card.Recurs {
+			card.Recurrence = nil
+		}
+	}
+	if o.Recurrence != nil {
+		card.Recurs = true
+		card.Recurrence = o.Recurrence
+	}
+	if o.MCCControl != nil {
+		card.MCCControl = o.MCCControl
+		if o.MCCControl.empty() {
+			card.MCCControl = nil
+		}
+	}
+	if o.ValidFrom != nil {
+		card.ValidFrom = &Time{Time: cardMidnight(*o.ValidFrom, card.Timezone)}
+	}
+	if o.ValidTo != nil {
+		card.ValidTo = &Time{Time: cardMidnight(*o.ValidTo, card.Timezone)}
+	}
+	if o.Currency != nil {
+		card.Currency = string(*o.Currency)
+	}
+	if o.ReceiptRulesExempt != nil {
+		card.ReceiptRulesExempt = *o.ReceiptRulesExempt
+	}
+}
 
-	Currency           Currency `json:"currency"`
-	ReceiptRulesExempt bool     `json:"receiptRulesExempt"`
+// updateVirtualCardPayload is the full card sent by an update, Extend
+// overwrites every field. BalanceCents is the limit of the card, not the
+// money left on it.
+type updateVirtualCardPayload struct {
+	CreditCardID       string      `json:"creditCardId"`
+	DisplayName        string      `json:"displayName"`
+	BalanceCents       int         `json:"balanceCents"`
+	Recurs             bool        `json:"recurs"`
+	Recurrence         *Recurrence `json:"recurrence,omitempty"`
+	MCCControl         *MCCControl `json:"mccControl,omitempty"`
+	ValidFrom          string      `json:"validFrom,omitempty"`
+	ValidTo            string      `json:"validTo,omitempty"`
+	Timezone           string      `json:"timezone,omitempty"`
+	Currency           string      `json:"currency"`
+	ReceiptRulesExempt bool        `json:"receiptRulesExempt"`
 }
 
-type updateVirtualCardOptions struct {
-	UpdateVirtualCardOptions
-	ValidTo string `json:"validTo"`
+func newUpdateVirtualCardPayload(card *VirtualCard) updateVirtualCardPayload {
+	payload := updateVirtualCardPayload{
+		CreditCardID:       card.CreditCardID,
+		DisplayName:        card.DisplayName,
+		BalanceCents:       card.LimitCents,
+		Recurs:             card.Recurs,
+		Recurrence:         card.Recurrence,
+		MCCControl:         card.MCCControl,
+		Currency:           card.Currency,
+		ReceiptRulesExempt: card.ReceiptRulesExempt,
+		Timezone:           card.Timezone,
+	}
+	if card.ValidFrom != nil {
+		payload.ValidFrom = cardDate(card.ValidFrom.Time, card.Timezone)
+	}
+	if card.ValidTo != nil {
+		payload.ValidTo = cardDate(card.ValidTo.Time, card.Timezone)
+	}
+	return payload
 }
 
+// UpdateVirtualCard changes the fields set in options. The card is fetched
+// first and the fields that aren't set are sent with their current value,
+// overwriting any change made in between.
 func (a *Client) UpdateVirtualCard(ctx context.Context, id string, options UpdateVirtualCardOptions) (*VirtualCard, error) {
-	payload := updateVirtualCardOptions{
-		UpdateVirtualCardOptions: options,
-		ValidTo:                  options.ValidTo.Format("2006-01-02"),
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := a.GetVirtualCard(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+
+	if options.UpdatedAt != nil && (card.UpdatedAt == nil || !card.UpdatedAt.Equal(options.UpdatedAt.Time)) {
+		current := "never"
+		if card.UpdatedAt != nil {
+			current = card.UpdatedAt.Format(time.RFC3339Nano)
+		}
+		return nil, fmt.Errorf("%w: virtual card %s was updated at %s, expected %s",
+			ErrConflict, id, current, options.UpdatedAt.Format(time.RFC3339Nano))
+	}
+
+	options.apply(card)
+	if card.Recurs && card.Recurrence == nil {
+		return nil, validationError("recurrence is required to make virtual card %s recurring", id)
+	}
+	if card.ValidFrom != nil && card.ValidTo != nil && card.ValidFrom.After(card.ValidTo.Time) {
+		return nil, validationError("valid from date %s is after valid to date %s",
+			cardDate(card.ValidFrom.Time, card.Timezone), cardDate(card.ValidTo.Time, card.Timezone))
+	}
+
 	var response VirtualCardResponse
-	err := a.jsonRequest(ctx, http.MethodPut, fmt.Sprintf("/virtualcards/%s", id), payload, &response)
+	err = a.jsonRequest(ctx, &Request{
+		Operation:  "UpdateVirtualCard",
+		Method:     http.MethodPut,
+		Path:       fmt.Sprintf("/virtualcards/%s", id),
+		ResourceID: id,
+		synthetic: func(ctx context.Context) (any, error) {
+			updated := *card
+			updated.UpdatedAt = dryRunTime(time.Now())
+			return VirtualCardResponse{VirtualCard: updated}, nil
+		},
+	}, newUpdateVirtualCardPayload(card), &response)
 	if err != nil {
 		return nil, err
 	}
@@ -75,7 +446,12 @@ func (a *Client) UpdateVirtualCard(ctx context.Context, id string, options Updat
 
 func (a *Client) GetVirtualCard(ctx context.Context, id string) (*VirtualCard, error) {
 	var response VirtualCardResponse
-	err := a.jsonRequest(ctx, http.MethodGet, fmt.Sprintf("/virtualcards/%s", id), nil, &response)
+	err := a.jsonRequest(ctx, &Request{
+		Operation:  "GetVirtualCard",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/virtualcards/%s", id),
+		ResourceID: id,
+	}, nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +461,15 @@ func (a *Client) GetVirtualCard(ctx context.Context, id string) (*VirtualCard, e
 
 func (a *Client) CancelVirtualCard(ctx context.Context, id string) (*VirtualCard, error) {
 	var response VirtualCardResponse
-	err := a.jsonRequest(ctx, http.MethodPut, fmt.Sprintf("/virtualcards/%s/cancel", id), nil, &response)
+	err := a.jsonRequest(ctx, &Request{
+		Operation:  "CancelVirtualCard",
+		Method:     http.MethodPut,
+		Path:       fmt.Sprintf("/virtualcards/%s/cancel", id),
+		ResourceID: id,
+		synthetic: a.syntheticCard(id, func(card *VirtualCard) {
+			card.Status = VirtualCardStatusCancelled
+		}),
+	}, nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +479,15 @@ func (a *Client) CancelVirtualCard(ctx context.Context, id string) (*VirtualCard
 
 func (a *Client) CloseVirtualCard(ctx context.Context, id string) (*VirtualCard, error) {
 	var response VirtualCardResponse
-	err := a.jsonRequest(ctx, http.MethodPut, fmt.Sprintf("/virtualcards/%s/close", id), nil, &response)
+	err := a.jsonRequest(ctx, &Request{
+		Operation:  "CloseVirtualCard",
+		Method:     http.MethodPut,
+		Path:       fmt.Sprintf("/virtualcards/%s/close", id),
+		ResourceID: id,
+		synthetic: a.syntheticCard(id, func(card *VirtualCard) {
+			card.Status = VirtualCardStatusClosed
+		}),
+	}, nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +495,20 @@ func (a *Client) CloseVirtualCard(ctx context.Context, id string) (*VirtualCard,
 	return &response.VirtualCard, nil
 }
 
+// syntheticCard builds the dry-run result of a call changing an existing
+// card by applying change to its current state
+func (a *Client) syntheticCard(id string, change func(card *VirtualCard)) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		card, err := a.GetVirtualCard(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		change(card)
+		card.UpdatedAt = dryRunTime(time.Now())
+		return VirtualCardResponse{VirtualCard: *card}, nil
+	}
+}
+
 type VirtualCardResponse struct {
 	VirtualCard VirtualCard `json:"virtualCard"`
 }
@@ -124,7 +530,7 @@ func (r ListVirtualCardsResponse) Items() []VirtualCard {
 }
 
 func (c *Client) ListVirtualCards(options *ListVirtualCardsOptions) *Paginator[VirtualCard, ListVirtualCardsResponse] {
-	return newPaginator[VirtualCard, ListVirtualCardsResponse](c, options.PaginationOptions, "/virtualcards", url.Values{
+	return newPaginator[VirtualCard, ListVirtualCardsResponse](c, "ListVirtualCards", options.PaginationOptions, "/virtualcards", url.Values{
 		"cardholderOrViewer": {options.CardholderOrViewer},
 		"issued":             {strconv.FormatBool(options.Issued)},
 		"statuses":           {join(options.Statuses, ",")},
@@ -166,6 +572,8 @@ type VirtualCardIssuer struct {
 	Code string `json:"code"`
 }
 
+// VirtualCard never holds the card number and security code, use
+// RevealVirtualCard to get them
 type VirtualCard struct {
 	ID     string            `json:"id"`
 	Status VirtualCardStatus `json:"status"`
@@ -176,14 +584,12 @@ type VirtualCard struct {
 	CardholderID string `json:"cardholderId"`
 	Cardholder   User   `json:"cardholder"`
 
-	Vcn          *string `json:"vcn,omitempty"`
-	SecurityCode *string `json:"securityCode,omitempty"`
-
 	LastUpdatedBy *User `json:"lastUpdatedBy,omitempty"`
 
 	CardImage          VirtualCardImage `json:"cardImage"`
 	CardType           string           `json:"cardType"`
 	DisplayName        string           `json:"displayName"`
+	Notes              string           `json:"notes"`
 	Currency           string           `json:"currency"`
 	LimitCents         int              `json:"limitCents"`
 	BalanceCents       int              `json:"balanceCents"`
@@ -200,9 +606,11 @@ type VirtualCard struct {
 	UpdatedAt     *Time `json:"updatedAt"`
 	ActiveUntil   *Time `json:"activeUntil"`
 
-	Timezone     string `json:"timezone"`
-	CreditCardID string `json:"creditCardId"`
-	Recurs       bool   `json:"recurs"`
+	Timezone     string      `json:"timezone"`
+	CreditCardID string      `json:"creditCardId"`
+	Recurs       bool        `json:"recurs"`
+	Recurrence   *Recurrence `json:"recurrence,omitempty"`
+	MCCControl   *MCCControl `json:"mccControl,omitempty"`
 
 	Address        Address             `json:"address"`
 	Features       VirtualCardFeatures `json:"features"`
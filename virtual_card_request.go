@@ -0,0 +1,304 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type VirtualCardRequestStatus string
+
+const (
+	VirtualCardRequestStatusPending   VirtualCardRequestStatus = "PENDING"
+	VirtualCardRequestStatusApproved  VirtualCardRequestStatus = "APPROVED"
+	VirtualCardRequestStatusDeclined  VirtualCardRequestStatus = "DECLINED"
+	VirtualCardRequestStatusCancelled VirtualCardRequestStatus = "CANCELLED"
+)
+
+// VirtualCardRequest is a card a cardholder asked for, issued once a manager
+// of the credit card approves it
+type VirtualCardRequest struct {
+	ID     string                   `json:"id"`
+	Status VirtualCardRequestStatus `json:"status"`
+
+	CreditCardID string `json:"creditCardId"`
+	RequesterID  string `json:"requesterId"`
+	Requester    User   `json:"requester"`
+	ReviewerID   string `json:"reviewerId,omitempty"`
+	Reviewer     *User  `json:"reviewer,omitempty"`
+
+	DisplayName  string `json:"displayName"`
+	BalanceCents int    `json:"balanceCents"`
+	Currency     string `json:"currency"`
+	Notes        string `json:"notes"`
+	ValidFrom    *Time  `json:"validFrom"`
+	ValidTo      *Time  `json:"validTo"`
+
+	DeclineReason string `json:"declineReason,omitempty"`
+
+	// VirtualCardID is the card issued when the request was approved
+	VirtualCardID string `json:"virtualCardId,omitempty"`
+
+	CreatedAt *Time `json:"createdAt"`
+	UpdatedAt *Time `json:"updatedAt"`
+}
+
+type VirtualCardRequestResponse struct {
+	VirtualCardRequest VirtualCardRequest `json:"virtualCardRequest"`
+}
+
+type RequestVirtualCardOptions struct {
+	CreditCardID string   `json:"creditCardId"`
+	DisplayName  string   `json:"displayName"`
+	BalanceCents int      `json:"balanceCents"`
+	Currency     Currency `json:"currency"`
+	// Notes explain what the card is for to the reviewer
+	Notes string `json:"notes"`
+	// ValidFrom and ValidTo are the requested validity (date only), the
+	// card is usable right away when ValidFrom is zero
+	ValidFrom time.Time `json:"-"`
+	ValidTo   time.Time `json:"-"`
+}
+
+type requestVirtualCardOptions struct {
+	RequestVirtualCardOptions
+	ValidFrom string `json:"validFrom,omitempty"`
+	ValidTo   string `json:"validTo"`
+}
+
+func (o RequestVirtualCardOptions) validate() error {
+	switch {
+	case o.CreditCardID == "":
+		return validationError("credit card ID is required")
+	case o.DisplayName == "":
+		return validationError("display name is required")
+	case o.BalanceCents <= 0:
+		return validationError("balance must be positive, got %d cents", o.BalanceCents)
+	case o.ValidTo.IsZero():
+		return validationError("valid to date is required")
+	case !o.ValidFrom.IsZero() && o.ValidFrom.Format(dateLayout) > o.ValidTo.Format(dateLayout):
+		return validationError("valid from date %s is after valid to date %s", o.ValidFrom.Format(dateLayout), o.ValidTo.Format(dateLayout))
+	}
+	return nil
+}
+
+// RequestVirtualCard submits a request for a card, to be approved or
+// declined by a manager of the credit card
+func (c *Client) RequestVirtualCard(ctx context.Context, options RequestVirtualCardOptions) (*VirtualCardRequest, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := requestVirtualCardOptions{
+		RequestVirtualCardOptions: options,
+		ValidTo:                   options.ValidTo.Format(dateLayout),
+	}
+	if !options.ValidFrom.IsZero() {
+		payload.ValidFrom = options.ValidFrom.Format(dateLayout)
+	}
+	var response VirtualCardRequestResponse
+	err = c.jsonRequest(ctx, &Request{
+		Operation: "RequestVirtualCard",
+		Method:    http.MethodPost,
+		Path:      "/virtualcardrequests",
+		synthetic: func(ctx context.Context) (any, error) {
+			now := time.Now()
+			return VirtualCardRequestResponse{VirtualCardRequest: VirtualCardRequest{
+				ID:           dryRunID(),
+				Status:       VirtualCardRequestStatusPending,
+				CreditCardID: options.CreditCardID,
+				DisplayName:  options.DisplayName,
+				BalanceCents: options.BalanceCents,
+				Currency:     string(options.Currency),
+				Notes:        options.Notes,
+				ValidFrom:    dryRunTime(options.ValidFrom),
+				ValidTo:      dryRunTime(options.ValidTo),
+				CreatedAt:    dryRunTime(now),
+				UpdatedAt:    dryRunTime(now),
+			}}, nil
+		},
+	}, payload, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.VirtualCardRequest, nil
+}
+
+func (c *Client) GetVirtualCardRequest(ctx context.Context, id string) (*VirtualCardRequest, error) {
+	var response VirtualCardRequestResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "GetVirtualCardRequest",
+		Method:     http.MethodGet,
+		Path:       fmt.Sprintf("/virtualcardrequests/%s", id),
+		ResourceID: id,
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.VirtualCardRequest, nil
+}
+
+type ListVirtualCardRequestsOptions struct {
+	PaginationOptions
+
+	// Statuses filters the requests, only pending ones are listed when empty
+	Statuses     []VirtualCardRequestStatus
+	CreditCardID string
+}
+
+type ListVirtualCardRequestsResponse struct {
+	PaginationResponse
+	VirtualCardRequests []VirtualCardRequest `json:"virtualCardRequests"`
+}
+
+func (r ListVirtualCardRequestsResponse) Items() []VirtualCardRequest {
+	return r.VirtualCardRequests
+}
+
+func (c *Client) ListVirtualCardRequests(options *ListVirtualCardRequestsOptions) *Paginator[VirtualCardRequest, ListVirtualCardRequestsResponse] {
+	statuses := options.Statuses
+	if len(statuses) == 0 {
+		statuses = []VirtualCardRequestStatus{VirtualCardRequestStatusPending}
+	}
+	query := url.Values{
+		"statuses": {join(statuses, ",")},
+	}
+	if options.CreditCardID != "" {
+		query.Set("creditCardId", options.CreditCardID)
+	}
+	return newPaginator[VirtualCardRequest, ListVirtualCardRequestsResponse](c, "ListVirtualCardRequests", options.PaginationOptions, "/virtualcardrequests", query)
+}
+
+// ApproveVirtualCardRequestOptions changes the requested card on approval,
+// the fields that aren't set are issued as requested
+type ApproveVirtualCardRequestOptions struct {
+	BalanceCents *int
+
+	// ValidFrom and ValidTo are the validity of the card (date only)
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+type approveVirtualCardRequestPayload struct {
+	BalanceCents *int   `json:"balanceCents,omitempty"`
+	ValidFrom    string `json:"validFrom,omitempty"`
+	ValidTo      string `json:"validTo,omitempty"`
+}
+
+func (o ApproveVirtualCardRequestOptions) validate() error {
+	switch {
+	case o.BalanceCents != nil && *o.BalanceCents <= 0:
+		return validationError("balance must be positive, got %d cents", *o.BalanceCents)
+	case o.ValidFrom != nil && o.ValidFrom.IsZero():
+		return validationError("valid from date must not be zero")
+	case o.ValidTo != nil && o.ValidTo.IsZero():
+		return validationError("valid to date must not be zero")
+	case o.ValidFrom != nil && o.ValidTo != nil && o.ValidFrom.Format(dateLayout) > o.ValidTo.Format(dateLayout):
+		return validationError("valid from date %s is after valid to date %s", o.ValidFrom.Format(dateLayout), o.ValidTo.Format(dateLayout))
+	}
+	return nil
+}
+
+// ApproveVirtualCardRequest approves a pending request and returns the card
+// issued for it. The call isn't retried once it may have reached Extend,
+// after such a failure GetVirtualCardRequest tells whether a card was
+// issued.
+func (c *Client) ApproveVirtualCardRequest(ctx context.Context, id string, options ApproveVirtualCardRequestOptions) (*VirtualCard, error) {
+	err := options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := approveVirtualCardRequestPayload{BalanceCents: options.BalanceCents}
+	if options.ValidFrom != nil {
+		payload.ValidFrom = options.ValidFrom.Format(dateLayout)
+	}
+	if options.ValidTo != nil {
+		payload.ValidTo = options.ValidTo.Format(dateLayout)
+	}
+	var response VirtualCardResponse
+	err = c.jsonRequest(ctx, &Request{
+		Operation:     "ApproveVirtualCardRequest",
+		Method:        http.MethodPut,
+		Path:          fmt.Sprintf("/virtualcardrequests/%s/approve", id),
+		ResourceID:    id,
+		NonIdempotent: true,
+		synthetic: func(ctx context.Context) (any, error) {
+			request, err := c.GetVirtualCardRequest(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			card := VirtualCard{
+				ID:           dryRunID(),
+				Status:       VirtualCardStatusActive,
+				DisplayName:  request.DisplayName,
+				Notes:        request.Notes,
+				Currency:     request.Currency,
+				LimitCents:   request.BalanceCents,
+				BalanceCents: request.BalanceCents,
+				CreditCardID: request.CreditCardID,
+				Cardholder:   request.Requester,
+				CardholderID: request.RequesterID,
+				ValidFrom:    request.ValidFrom,
+				ValidTo:      request.ValidTo,
+				CreatedAt:    dryRunTime(now),
+				UpdatedAt:    dryRunTime(now),
+			}
+			if options.BalanceCents != nil {
+				card.LimitCents = *options.BalanceCents
+				card.BalanceCents = *options.BalanceCents
+			}
+			if options.ValidFrom != nil {
+				card.ValidFrom = dryRunTime(*options.ValidFrom)
+			}
+			if options.ValidTo != nil {
+				card.ValidTo = dryRunTime(*options.ValidTo)
+			}
+			return VirtualCardResponse{VirtualCard: card}, nil
+		},
+	}, payload, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.VirtualCard, nil
+}
+
+// DeclineVirtualCardRequest declines a pending request, the reason is shown
+// to the requester
+func (c *Client) DeclineVirtualCardRequest(ctx context.Context, id string, reason string) (*VirtualCardRequest, error) {
+	if reason == "" {
+		return nil, validationError("decline reason is required")
+	}
+
+	var response VirtualCardRequestResponse
+	err := c.jsonRequest(ctx, &Request{
+		Operation:  "DeclineVirtualCardRequest",
+		Method:     http.MethodPut,
+		Path:       fmt.Sprintf("/virtualcardrequests/%s/decline", id),
+		ResourceID: id,
+		synthetic: func(ctx context.Context) (any, error) {
+			request, err := c.GetVirtualCardRequest(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			request.Status = VirtualCardRequestStatusDeclined
+			request.DeclineReason = reason
+			request.UpdatedAt = dryRunTime(time.Now())
+			return VirtualCardRequestResponse{VirtualCardRequest: *request}, nil
+		},
+	}, struct {
+		Reason string `json:"reason"`
+	}{reason}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.VirtualCardRequest, nil
+}
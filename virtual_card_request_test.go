@@ -0,0 +1,91 @@
+package extend_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestApproveVirtualCardRequestIsNotReplayed(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault extendtest.Fault
+		// status is the status of the error returned, 0 for no error
+		status int
+		cards  int
+	}{
+		{
+			name:  "success",
+			cards: 1,
+		},
+		{
+			name:   "lost response",
+			fault:  extendtest.Fault{Method: http.MethodPut, StatusCode: http.StatusBadGateway, Handled: true, Times: 1},
+			status: http.StatusBadGateway,
+			cards:  1,
+		},
+		{
+			name:   "gateway timeout before Extend",
+			fault:  extendtest.Fault{Method: http.MethodPut, StatusCode: http.StatusGatewayTimeout, Times: 1},
+			status: http.StatusGatewayTimeout,
+			cards:  0,
+		},
+		{
+			name:  "rate limited",
+			fault: extendtest.Fault{Method: http.MethodPut, StatusCode: http.StatusTooManyRequests, Times: 1},
+			cards: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+			server.SeedUser(extend.User{FirstName: "Jane", Email: "jane@example.com"})
+			request := server.SeedVirtualCardRequest(extend.VirtualCardRequest{
+				CreditCardID: "cc_1",
+				DisplayName:  "Offsite",
+				BalanceCents: 5000,
+				ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+			})
+			if test.fault.StatusCode != 0 {
+				server.InjectFault(test.fault)
+			}
+
+			client := server.Client()
+			card, err := client.ApproveVirtualCardRequest(context.Background(), request.ID, extend.ApproveVirtualCardRequestOptions{})
+
+			var apiErr *extend.APIError
+			switch {
+			case test.status == 0 && err != nil:
+				t.Fatalf("got error %v", err)
+			case test.status == 0 && card.BalanceCents != 5000:
+				t.Errorf("got card with balance %d, want 5000", card.BalanceCents)
+			case test.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != test.status):
+				t.Errorf("got error %v, want status %d", err, test.status)
+			}
+			if n := len(server.Cards()); n != test.cards {
+				t.Errorf("server holds %d cards, want %d", n, test.cards)
+			}
+
+			approvals := 0
+			for _, r := range server.Requests() {
+				if r.Method == http.MethodPut {
+					approvals++
+				}
+			}
+			want := 1
+			if test.fault.StatusCode == http.StatusTooManyRequests {
+				want = 2
+			}
+			if approvals != want {
+				t.Errorf("approve sent %d times, want %d", approvals, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package extend_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"local/extend"
+	"local/extend/extendtest"
+)
+
+func TestUpdateVirtualCardKeepsSpending(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     extend.UpdateVirtualCardOptions
+		displayName string
+		limit       int
+		balance     int
+	}{
+		{
+			name:        "rename",
+			options:     extend.UpdateVirtualCardOptions{DisplayName: extend.Ptr("renamed")},
+			displayName: "renamed",
+			limit:       10000,
+			balance:     4000,
+		},
+		{
+			name:        "raise limit",
+			options:     extend.UpdateVirtualCardOptions{BalanceCents: extend.Ptr(15000)},
+			displayName: "Travel",
+			limit:       15000,
+			balance:     9000,
+		},
+		{
+			name:        "lower limit",
+			options:     extend.UpdateVirtualCardOptions{BalanceCents: extend.Ptr(8000)},
+			displayName: "Travel",
+			limit:       8000,
+			balance:     2000,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := extendtest.NewServer()
+			defer server.Close()
+
+			seeded := server.SeedCard(extend.VirtualCard{
+				DisplayName:  "Travel",
+				CreditCardID: "cc_1",
+				LimitCents:   10000,
+				BalanceCents: 4000,
+				ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+			})
+
+			card, err := server.Client().UpdateVirtualCard(context.Background(), seeded.ID, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, _ := server.Card(seeded.ID)
+			for _, got := range []extend.VirtualCard{*card, stored} {
+				if got.DisplayName != test.displayName || got.LimitCents != test.limit || got.BalanceCents != test.balance {
+					t.Errorf("got %q with limit %d and balance %d, want %q with limit %d and balance %d",
+						got.DisplayName, got.LimitCents, got.BalanceCents, test.displayName, test.limit, test.balance)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateVirtualCardDryRunKeepsSpending(t *testing.T) {
+	server := extendtest.NewServer()
+	defer server.Close()
+
+	seeded := server.SeedCard(extend.VirtualCard{
+		DisplayName:  "Travel",
+		CreditCardID: "cc_1",
+		LimitCents:   10000,
+		BalanceCents: 4000,
+		ValidTo:      &extend.Time{Time: time.Now().AddDate(0, 1, 0)},
+	})
+
+	client := server.Client(extend.WithDryRun(nil))
+	card, err := client.UpdateVirtualCard(context.Background(), seeded.ID, extend.UpdateVirtualCardOptions{BalanceCents: extend.Ptr(12000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.LimitCents != 12000 || card.BalanceCents != 6000 {
+		t.Errorf("got limit %d and balance %d, want 12000 and 6000", card.LimitCents, card.BalanceCents)
+	}
+	if stored, _ := server.Card(seeded.ID); stored.LimitCents != 10000 || stored.BalanceCents != 4000 {
+		t.Errorf("dry run changed the card to limit %d and balance %d", stored.LimitCents, stored.BalanceCents)
+	}
+}